@@ -0,0 +1,85 @@
+package pudding
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bdjimmy/pudding/auth"
+	"github.com/bdjimmy/pudding/ecode"
+	"github.com/bdjimmy/pudding/metadata"
+)
+
+// JWTAuth verifies the bearer token in the Authorization header, e.g.
+// engine.SetAuthenticator(auth.ModeJWT, JWTAuth(verifier)) for the routes with MethodConfig.Auth "jwt".
+// The verified identity is put into metadata and its subject replaces metadata.Caller,
+// the unauthenticated requests get 401 with the Bearer WWW-Authenticate header
+// 校验Authorization中的Bearer token, 校验通过后用token的sub覆盖调用方
+func JWTAuth(v *auth.JWTVerifier) HandlerFunc {
+	return func(c *Context) {
+		token := c.Request.Header.Get("Authorization")
+		if len(token) > 7 && strings.EqualFold(token[:7], "Bearer ") {
+			token = strings.TrimSpace(token[7:])
+		} else {
+			token = ""
+		}
+		id, err := v.Verify(token, time.Now())
+		challenge := "Bearer"
+		if token != "" {
+			challenge = `Bearer error="invalid_token"`
+		}
+		c.authenticated(id, err, challenge)
+	}
+}
+
+// SignAuth verifies the requests signed by auth.Signer with the appkey, ts and sign params
+// in the query or the body form, the appkey replaces metadata.Caller, the unauthenticated requests get 401
+// 校验服务间调用的签名, 参数可以在query或者表单中, 校验通过后用appkey覆盖调用方
+func SignAuth(v *auth.SignVerifier) HandlerFunc {
+	return func(c *Context) {
+		if err := c.ParseForm(); err != nil {
+			c.AbortWithBodyError(err)
+			return
+		}
+		id, err := v.Verify(c.Request.Method, c.Request.URL.Path, c.Request.Form, time.Now())
+		c.authenticated(id, err, "")
+	}
+}
+
+// BasicAuth verifies the HTTP Basic credentials, the user replaces metadata.Caller,
+// the unauthenticated requests get 401 with the WWW-Authenticate header
+func BasicAuth(v *auth.BasicVerifier) HandlerFunc {
+	return func(c *Context) {
+		user, password, _ := c.Request.BasicAuth()
+		id, err := v.Verify(user, password)
+		c.authenticated(id, err, `Basic realm="`+v.Realm()+`"`)
+	}
+}
+
+// authenticated 校验失败时返回401, 成功时把身份写入metadata并覆盖请求头中的调用方
+func (c *Context) authenticated(id *auth.Identity, err error, challenge string) {
+	if err != nil {
+		c.abortUnauthorized(challenge, err)
+		return
+	}
+	// FromContext返回的MD不能修改, 复制一份再写入
+	md, _ := metadata.FromContext(c)
+	md = md.Copy()
+	md[metadata.Caller] = id.Subject
+	md[metadata.Identity] = id
+	c.Context = metadata.NewContext(c.Context, md)
+}
+
+// abortUnauthorized 所有鉴权方式失败时都返回HTTP 401和ecode.Unauthorized,
+// challenge不为空时写入 WWW-Authenticate
+func (c *Context) abortUnauthorized(challenge string, err error) {
+	if challenge != "" {
+		c.Writer.Header().Set("WWW-Authenticate", challenge)
+	}
+	var berr error = ecode.Unauthorized
+	if err != nil {
+		berr = ecode.Wrap(ecode.Unauthorized, err)
+	}
+	c.renderJSON(http.StatusUnauthorized, nil, berr)
+	c.Abort()
+}
@@ -0,0 +1,42 @@
+// Package auth verifies the callers: JWT bearer tokens (HS256/RS256/ES256) with a local key set,
+// HMAC signed app keys for service-to-service calls, and HTTP Basic.
+// The verified identity is put into metadata by the pudding middleware, see pudding.JWTAuth
+package auth
+
+import (
+	"context"
+
+	"github.com/bdjimmy/pudding/metadata"
+	"github.com/pkg/errors"
+)
+
+// auth modes
+const (
+	ModeJWT   = "jwt"
+	ModeSign  = "sign"
+	ModeBasic = "basic"
+)
+
+// common errors, the errors returned by the verifiers wrap them with the details
+var (
+	ErrMissingCredentials = errors.New("auth: missing credentials")
+	ErrInvalidCredentials = errors.New("auth: invalid credentials")
+	ErrExpired            = errors.New("auth: credentials expired")
+	ErrReplayed           = errors.New("auth: request replayed")
+)
+
+// Identity is the verified caller
+type Identity struct {
+	// Subject 调用方的身份: JWT的sub, 签名的appkey或者Basic的用户名, 会写入metadata.Caller
+	Subject string `json:"subject"`
+	// Mode 鉴权方式
+	Mode string `json:"mode"`
+	// Claims JWT中的声明, 其他方式为空
+	Claims Claims `json:"claims,omitempty"`
+}
+
+// FromContext returns the verified identity in the metadata of ctx
+func FromContext(ctx context.Context) (*Identity, bool) {
+	id, ok := metadata.Value(ctx, metadata.Identity).(*Identity)
+	return id, ok
+}
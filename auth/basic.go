@@ -0,0 +1,63 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+)
+
+// BasicConfig is the config of the HTTP Basic verifier
+type BasicConfig struct {
+	// Realm 认证失败时 WWW-Authenticate 中的realm, 默认为 Restricted
+	Realm string
+	// Users 用户名和密码
+	Users map[string]string
+	// Validate 自定义的校验, Users中没有的用户会调用Validate
+	Validate func(user, password string) bool
+}
+
+// BasicVerifier verifies the HTTP Basic credentials
+type BasicVerifier struct {
+	realm    string
+	users    map[string][32]byte
+	validate func(user, password string) bool
+}
+
+// NewBasicVerifier new a HTTP Basic verifier
+func NewBasicVerifier(conf *BasicConfig) *BasicVerifier {
+	v := &BasicVerifier{realm: "Restricted", users: make(map[string][32]byte)}
+	if conf == nil {
+		return v
+	}
+	if conf.Realm != "" {
+		v.realm = conf.Realm
+	}
+	// 保存密码的摘要, 比较时长度固定, 不会泄露密码长度
+	for user, password := range conf.Users {
+		v.users[user] = sha256.Sum256([]byte(password))
+	}
+	v.validate = conf.Validate
+	return v
+}
+
+// Realm returns the realm of the WWW-Authenticate header
+func (v *BasicVerifier) Realm() string {
+	return v.realm
+}
+
+// Verify verifies the user and the password and returns the identity of the user
+func (v *BasicVerifier) Verify(user, password string) (*Identity, error) {
+	if user == "" {
+		return nil, ErrMissingCredentials
+	}
+	if want, ok := v.users[user]; ok {
+		got := sha256.Sum256([]byte(password))
+		if subtle.ConstantTimeCompare(got[:], want[:]) == 1 {
+			return &Identity{Subject: user, Mode: ModeBasic}, nil
+		}
+		return nil, ErrInvalidCredentials
+	}
+	if v.validate != nil && v.validate(user, password) {
+		return &Identity{Subject: user, Mode: ModeBasic}, nil
+	}
+	return nil, ErrInvalidCredentials
+}
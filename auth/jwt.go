@@ -0,0 +1,359 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// JWT algorithms
+const (
+	HS256 = "HS256"
+	RS256 = "RS256"
+	ES256 = "ES256"
+)
+
+// Claims is the JWT claims set
+type Claims map[string]interface{}
+
+// Subject returns the sub claim
+func (c Claims) Subject() string {
+	s, _ := c["sub"].(string)
+	return s
+}
+
+// time 读取数值类型的时间声明, 例如exp, 不存在时ok为false, 存在但不是数字时返回错误
+func (c Claims) time(name string) (t time.Time, ok bool, err error) {
+	raw, ok := c[name]
+	if !ok {
+		return
+	}
+	v, isNum := raw.(float64)
+	if !isNum {
+		err = errors.Wrapf(ErrInvalidCredentials, "malformed %s: %v", name, raw)
+		return
+	}
+	return time.Unix(int64(v), 0), true, nil
+}
+
+// audience aud可以是字符串或者字符串数组
+func (c Claims) audience() []string {
+	switch aud := c["aud"].(type) {
+	case string:
+		return []string{aud}
+	case []interface{}:
+		auds := make([]string, 0, len(aud))
+		for _, a := range aud {
+			if s, ok := a.(string); ok {
+				auds = append(auds, s)
+			}
+		}
+		return auds
+	}
+	return nil
+}
+
+// jwtHeader JOSE头
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid,omitempty"`
+	Typ string `json:"typ,omitempty"`
+}
+
+// KeySet is the local set of the verification keys keyed by the kid header,
+// the key with the empty kid is used by the tokens without kid
+// 本地的验签密钥, 按kid查找, 没有kid的token使用kid为空的密钥
+type KeySet struct {
+	mu   sync.RWMutex
+	keys map[string]interface{}
+}
+
+// NewKeySet new an empty key set
+func NewKeySet() *KeySet {
+	return &KeySet{keys: make(map[string]interface{})}
+}
+
+// AddHMAC adds the HS256 secret
+func (ks *KeySet) AddHMAC(kid string, secret []byte) {
+	ks.add(kid, secret)
+}
+
+// AddRSA adds the RS256 public key
+func (ks *KeySet) AddRSA(kid string, pub *rsa.PublicKey) {
+	ks.add(kid, pub)
+}
+
+// AddECDSA adds the ES256 public key, the curve must be P-256
+func (ks *KeySet) AddECDSA(kid string, pub *ecdsa.PublicKey) error {
+	if pub.Curve != elliptic.P256() {
+		return errors.Errorf("auth: ES256 requires a P-256 key, kid:%s", kid)
+	}
+	ks.add(kid, pub)
+	return nil
+}
+
+// AddPEM adds the RSA or ECDSA public key, or the public key of the certificate, encoded in PEM
+func (ks *KeySet) AddPEM(kid string, data []byte) error {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return errors.Errorf("auth: no PEM data, kid:%s", kid)
+	}
+	var (
+		pub interface{}
+		err error
+	)
+	switch block.Type {
+	case "RSA PUBLIC KEY":
+		pub, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	case "CERTIFICATE":
+		var cert *x509.Certificate
+		if cert, err = x509.ParseCertificate(block.Bytes); err == nil {
+			pub = cert.PublicKey
+		}
+	default:
+		pub, err = x509.ParsePKIXPublicKey(block.Bytes)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "auth: parse PEM, kid:%s", kid)
+	}
+	switch key := pub.(type) {
+	case *rsa.PublicKey:
+		ks.AddRSA(kid, key)
+		return nil
+	case *ecdsa.PublicKey:
+		return ks.AddECDSA(kid, key)
+	}
+	return errors.Errorf("auth: unsupported public key %T, kid:%s", pub, kid)
+}
+
+// Remove removes the key, e.g. after the key rotation
+func (ks *KeySet) Remove(kid string) {
+	ks.mu.Lock()
+	delete(ks.keys, kid)
+	ks.mu.Unlock()
+}
+
+func (ks *KeySet) add(kid string, key interface{}) {
+	ks.mu.Lock()
+	ks.keys[kid] = key
+	ks.mu.Unlock()
+}
+
+func (ks *KeySet) get(kid string) (interface{}, bool) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	key, ok := ks.keys[kid]
+	return key, ok
+}
+
+// JWTConfig is the config of the JWT verifier
+type JWTConfig struct {
+	// Keys 验签密钥
+	Keys *KeySet
+	// Issuer 不为空时校验iss
+	Issuer string
+	// Audience 不为空时校验aud中包含Audience
+	Audience string
+	// Leeway 校验exp和nbf时允许的时钟误差
+	Leeway time.Duration
+}
+
+// JWTVerifier verifies the JWT bearer tokens, the alg of the token must match the type of the key,
+// the tokens without exp are accepted and the sub claim is required
+// 校验JWT, alg必须和密钥的类型一致, 防止用公钥作为HMAC密钥伪造token
+type JWTVerifier struct {
+	conf JWTConfig
+}
+
+// NewJWTVerifier new a JWT verifier
+func NewJWTVerifier(conf *JWTConfig) *JWTVerifier {
+	v := &JWTVerifier{}
+	if conf != nil {
+		v.conf = *conf
+	}
+	if v.conf.Keys == nil {
+		v.conf.Keys = NewKeySet()
+	}
+	return v
+}
+
+// Verify verifies the token at now and returns the identity
+func (v *JWTVerifier) Verify(token string, now time.Time) (*Identity, error) {
+	if token == "" {
+		return nil, ErrMissingCredentials
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.Wrap(ErrInvalidCredentials, "malformed token")
+	}
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, err
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.Wrap(ErrInvalidCredentials, "malformed signature")
+	}
+	key, ok := v.conf.Keys.get(header.Kid)
+	if !ok {
+		return nil, errors.Wrapf(ErrInvalidCredentials, "unknown kid:%s", header.Kid)
+	}
+	if err = verifySignature(header.Alg, key, parts[0]+"."+parts[1], sig); err != nil {
+		return nil, err
+	}
+	claims := make(Claims)
+	if err = decodeSegment(parts[1], &claims); err != nil {
+		return nil, err
+	}
+	if err = v.validate(claims, now); err != nil {
+		return nil, err
+	}
+	return &Identity{Subject: claims.Subject(), Mode: ModeJWT, Claims: claims}, nil
+}
+
+// validate 校验时间、签发者、受众和sub
+func (v *JWTVerifier) validate(claims Claims, now time.Time) error {
+	exp, ok, err := claims.time("exp")
+	if err != nil {
+		return err
+	}
+	if ok && now.After(exp.Add(v.conf.Leeway)) {
+		return errors.Wrapf(ErrExpired, "exp:%s", exp)
+	}
+	nbf, ok, err := claims.time("nbf")
+	if err != nil {
+		return err
+	}
+	if ok && now.Add(v.conf.Leeway).Before(nbf) {
+		return errors.Wrapf(ErrInvalidCredentials, "not valid before:%s", nbf)
+	}
+	if v.conf.Issuer != "" {
+		if iss, _ := claims["iss"].(string); iss != v.conf.Issuer {
+			return errors.Wrapf(ErrInvalidCredentials, "unexpected iss:%s", iss)
+		}
+	}
+	if v.conf.Audience != "" {
+		found := false
+		for _, aud := range claims.audience() {
+			found = found || aud == v.conf.Audience
+		}
+		if !found {
+			return errors.Wrap(ErrInvalidCredentials, "unexpected aud")
+		}
+	}
+	if claims.Subject() == "" {
+		return errors.Wrap(ErrInvalidCredentials, "missing sub")
+	}
+	return nil
+}
+
+// verifySignature 按alg和密钥的类型验签
+func verifySignature(alg string, key interface{}, signed string, sig []byte) error {
+	digest := sha256.Sum256([]byte(signed))
+	switch k := key.(type) {
+	case []byte:
+		if alg != HS256 {
+			break
+		}
+		mac := hmac.New(sha256.New, k)
+		mac.Write([]byte(signed))
+		if !hmac.Equal(mac.Sum(nil), sig) {
+			return errors.Wrap(ErrInvalidCredentials, "signature mismatch")
+		}
+		return nil
+	case *rsa.PublicKey:
+		if alg != RS256 {
+			break
+		}
+		if err := rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], sig); err != nil {
+			return errors.Wrap(ErrInvalidCredentials, "signature mismatch")
+		}
+		return nil
+	case *ecdsa.PublicKey:
+		if alg != ES256 {
+			break
+		}
+		if len(sig) != 64 {
+			return errors.Wrap(ErrInvalidCredentials, "malformed ES256 signature")
+		}
+		r := new(big.Int).SetBytes(sig[:32])
+		s := new(big.Int).SetBytes(sig[32:])
+		if !ecdsa.Verify(k, digest[:], r, s) {
+			return errors.Wrap(ErrInvalidCredentials, "signature mismatch")
+		}
+		return nil
+	}
+	return errors.Wrapf(ErrInvalidCredentials, "alg %s doesn't match the key", alg)
+}
+
+// SignJWT signs the claims with the key, the alg is HS256 for []byte, RS256 for *rsa.PrivateKey
+// and ES256 for *ecdsa.PrivateKey, kid is omitted if it's empty
+func SignJWT(claims Claims, kid string, key interface{}) (string, error) {
+	header := jwtHeader{Kid: kid, Typ: "JWT"}
+	switch key.(type) {
+	case []byte:
+		header.Alg = HS256
+	case *rsa.PrivateKey:
+		header.Alg = RS256
+	case *ecdsa.PrivateKey:
+		header.Alg = ES256
+	default:
+		return "", errors.Errorf("auth: unsupported signing key %T", key)
+	}
+	h, err := json.Marshal(header)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	c, err := json.Marshal(claims)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	signed := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c)
+	digest := sha256.Sum256([]byte(signed))
+	var sig []byte
+	switch k := key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, k)
+		mac.Write([]byte(signed))
+		sig = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		if sig, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:]); err != nil {
+			return "", errors.WithStack(err)
+		}
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest[:])
+		if err != nil {
+			return "", errors.WithStack(err)
+		}
+		sig = make([]byte, 64)
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// decodeSegment 解码base64url编码的JSON
+func decodeSegment(seg string, v interface{}) error {
+	bs, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return errors.Wrap(ErrInvalidCredentials, "malformed segment")
+	}
+	if err = json.Unmarshal(bs, v); err != nil {
+		return errors.Wrap(ErrInvalidCredentials, "malformed JSON segment")
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestJWTTimeClaims(t *testing.T) {
+	secret := []byte("secret")
+	keys := NewKeySet()
+	keys.AddHMAC("", secret)
+	v := NewJWTVerifier(&JWTConfig{Keys: keys})
+	now := time.Now()
+	cases := []struct {
+		name   string
+		claims Claims
+		want   error
+	}{
+		{"no exp", Claims{"sub": "alice"}, nil},
+		{"valid", Claims{"sub": "alice", "exp": now.Add(time.Minute).Unix(), "nbf": now.Add(-time.Minute).Unix()}, nil},
+		{"expired", Claims{"sub": "alice", "exp": now.Add(-time.Minute).Unix()}, ErrExpired},
+		{"not yet valid", Claims{"sub": "alice", "nbf": now.Add(time.Minute).Unix()}, ErrInvalidCredentials},
+		{"string exp", Claims{"sub": "alice", "exp": "tomorrow"}, ErrInvalidCredentials},
+		{"null exp", Claims{"sub": "alice", "exp": nil}, ErrInvalidCredentials},
+		{"string nbf", Claims{"sub": "alice", "nbf": "1"}, ErrInvalidCredentials},
+	}
+	for _, cs := range cases {
+		token, err := SignJWT(cs.claims, "", secret)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = v.Verify(token, now)
+		if errors.Cause(err) != cs.want {
+			t.Errorf("%s: got %v, want %v", cs.name, err, cs.want)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// the params of the signed requests
+const (
+	ParamAppKey    = "appkey"
+	ParamTimestamp = "ts"
+	ParamSign      = "sign"
+)
+
+// Signer signs the requests with the app key and the secret
+// 使用appkey和secret给请求签名, 用于服务间调用
+type Signer struct {
+	Key    string
+	Secret string
+}
+
+// Sign returns a copy of params with the appkey, ts and sign params set,
+// the sign is hex(HMAC-SHA256(secret, method + "\n" + path + "\n" + sorted encoded params))
+func (s *Signer) Sign(method, path string, params url.Values, now time.Time) url.Values {
+	signed := make(url.Values, len(params)+3)
+	for k, vs := range params {
+		signed[k] = append([]string(nil), vs...)
+	}
+	signed.Del(ParamSign)
+	signed.Set(ParamAppKey, s.Key)
+	signed.Set(ParamTimestamp, strconv.FormatInt(now.Unix(), 10))
+	signed.Set(ParamSign, sign(s.Secret, method, path, signed))
+	return signed
+}
+
+// sign 计算签名, 不包括sign参数, url.Values.Encode 按key排序
+func sign(secret, method, path string, params url.Values) string {
+	sorted := params
+	if _, ok := params[ParamSign]; ok {
+		sorted = make(url.Values, len(params))
+		for k, vs := range params {
+			if k != ParamSign {
+				sorted[k] = vs
+			}
+		}
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(method + "\n" + path + "\n" + sorted.Encode()))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SignConfig is the config of the signature verifier
+type SignConfig struct {
+	// Secrets appkey对应的secret
+	Secrets map[string]string
+	// Window 允许的时间戳误差, 窗口内同一个签名只能使用一次, 默认5分钟
+	Window time.Duration
+}
+
+// SignVerifier verifies the signed requests, the timestamp must be in the window
+// and a signature is accepted only once in the window
+// 校验签名, 时间戳必须在窗口内, 并且窗口内同一个签名只能使用一次, 防止重放
+type SignVerifier struct {
+	secrets map[string]string
+	window  time.Duration
+
+	mu    sync.Mutex
+	seen  map[string]time.Time
+	sweep time.Time
+}
+
+// NewSignVerifier new a signature verifier
+func NewSignVerifier(conf *SignConfig) *SignVerifier {
+	v := &SignVerifier{secrets: make(map[string]string), window: 5 * time.Minute, seen: make(map[string]time.Time)}
+	if conf != nil {
+		for k, s := range conf.Secrets {
+			v.secrets[k] = s
+		}
+		if conf.Window > 0 {
+			v.window = conf.Window
+		}
+	}
+	return v
+}
+
+// Verify verifies the params of the request at now and returns the identity of the appkey
+func (v *SignVerifier) Verify(method, path string, params url.Values, now time.Time) (*Identity, error) {
+	key, ts, got := params.Get(ParamAppKey), params.Get(ParamTimestamp), params.Get(ParamSign)
+	if key == "" || ts == "" || got == "" {
+		return nil, ErrMissingCredentials
+	}
+	secret, ok := v.secrets[key]
+	if !ok {
+		return nil, errors.Wrapf(ErrInvalidCredentials, "unknown appkey:%s", key)
+	}
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return nil, errors.Wrapf(ErrInvalidCredentials, "malformed ts:%s", ts)
+	}
+	signedAt := time.Unix(unix, 0)
+	if d := now.Sub(signedAt); d > v.window || d < -v.window {
+		return nil, errors.Wrapf(ErrExpired, "ts:%s", ts)
+	}
+	if !hmac.Equal([]byte(sign(secret, method, path, params)), []byte(got)) {
+		return nil, errors.Wrap(ErrInvalidCredentials, "signature mismatch")
+	}
+	if !v.remember(got, signedAt.Add(v.window), now) {
+		return nil, ErrReplayed
+	}
+	return &Identity{Subject: key, Mode: ModeSign}, nil
+}
+
+// remember 记录窗口内使用过的签名, 已经使用过时返回false
+func (v *SignVerifier) remember(sig string, expire, now time.Time) bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if now.After(v.sweep) {
+		for s, exp := range v.seen {
+			if now.After(exp) {
+				delete(v.seen, s)
+			}
+		}
+		v.sweep = now.Add(v.window)
+	}
+	if _, ok := v.seen[sig]; ok {
+		return false
+	}
+	v.seen[sig] = expire
+	return true
+}
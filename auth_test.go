@@ -0,0 +1,54 @@
+package pudding
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bdjimmy/pudding/auth"
+)
+
+func TestAuthUnauthorizedStatus(t *testing.T) {
+	engine := New()
+	engine.SetAuthenticator(auth.ModeJWT, JWTAuth(auth.NewJWTVerifier(nil)))
+	engine.SetAuthenticator(auth.ModeSign, SignAuth(auth.NewSignVerifier(&auth.SignConfig{Secrets: map[string]string{"app": "secret"}})))
+	engine.SetAuthenticator(auth.ModeBasic, BasicAuth(auth.NewBasicVerifier(&auth.BasicConfig{Realm: "pudding", Users: map[string]string{"u": "p"}})))
+	ok := func(c *Context) { c.String(http.StatusOK, "ok") }
+	for _, mode := range []string{auth.ModeJWT, auth.ModeSign, auth.ModeBasic, "missing"} {
+		engine.GET("/"+mode, ok)
+		engine.SetMethodConfig("/"+mode, &MethodConfig{Auth: mode})
+	}
+
+	cases := []struct {
+		path, authorization, challenge string
+	}{
+		{"/jwt", "", "Bearer"},
+		{"/jwt", "Bearer a.b.c", `Bearer error="invalid_token"`},
+		{"/sign?appkey=app&ts=1&sign=x", "", ""},
+		{"/basic", "", `Basic realm="pudding"`},
+		{"/missing", "", ""},
+	}
+	for _, cs := range cases {
+		req := httptest.NewRequest(http.MethodGet, cs.path, nil)
+		if cs.authorization != "" {
+			req.Header.Set("Authorization", cs.authorization)
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		if w.Code != http.StatusUnauthorized || !strings.Contains(w.Body.String(), `"code":-401`) {
+			t.Errorf("%s: %d %s", cs.path, w.Code, w.Body.String())
+		}
+		if got := w.Header().Get("WWW-Authenticate"); got != cs.challenge {
+			t.Errorf("%s: WWW-Authenticate %q, want %q", cs.path, got, cs.challenge)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/basic", nil)
+	req.SetBasicAuth("u", "p")
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Body.String() != "ok" {
+		t.Errorf("basic: %d %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,93 @@
+// Package binding decodes request data into structs and validates them by struct tags
+package binding
+
+import (
+	"net/http"
+	"strings"
+)
+
+// MIME types
+const (
+	MIMEJSON              = "application/json"
+	MIMEHTML              = "text/html"
+	MIMEXML               = "application/xml"
+	MIMEXML2              = "text/xml"
+	MIMEPlain             = "text/plain"
+	MIMEPOSTForm          = "application/x-www-form-urlencoded"
+	MIMEMultipartPOSTForm = "multipart/form-data"
+)
+
+const (
+	// 解析multipart时放在内存中的最大字节数, 超出部分写入临时文件
+	defaultMaxMemory = 32 << 20 // 32 MB
+)
+
+// Binding http binding request interface
+type Binding interface {
+	Name() string
+	Bind(*http.Request, interface{}) error
+}
+
+// MultipartBinding is implemented by the bindings parsing multipart forms,
+// the caller passes in the max memory, e.g. the limit of the engine and the route
+// 解析multipart表单的binding, 由调用方传入放在内存中的最大字节数
+type MultipartBinding interface {
+	Binding
+	BindMaxMemory(req *http.Request, obj interface{}, maxMemory int64) error
+}
+
+// StructValidator http validator interface
+type StructValidator interface {
+	// ValidateStruct can receive any kind of type and it should never panic, even if the configuration is not right.
+	// If the received type is not a struct, any validation should be skipped and nil must be returned.
+	// If the received type is a struct or pointer to a struct, the validation should be performed.
+	// If the struct is not valid or the validation itself fails, a descriptive error should be returned.
+	// Otherwise nil must be returned.
+	ValidateStruct(interface{}) error
+}
+
+// Validator default validator, it can be replaced by a custom one
+var Validator StructValidator = &defaultValidator{}
+
+// bindings
+var (
+	JSON          = jsonBinding{}
+	XML           = xmlBinding{}
+	Form          = formBinding{}
+	Query         = queryBinding{}
+	FormPost      = formPostBinding{}
+	FormMultipart = formMultipartBinding{}
+)
+
+// Default get by binding type by method and content-type
+// 根据请求方法和Content-Type选择解析方式
+func Default(method, contentType string) Binding {
+	if method == http.MethodGet {
+		return Form
+	}
+
+	switch stripContentTypeParam(contentType) {
+	case MIMEJSON:
+		return JSON
+	case MIMEXML, MIMEXML2:
+		return XML
+	case MIMEMultipartPOSTForm:
+		return FormMultipart
+	default:
+		return Form
+	}
+}
+
+func stripContentTypeParam(contentType string) string {
+	if i := strings.IndexByte(contentType, ';'); i != -1 {
+		contentType = contentType[:i]
+	}
+	return strings.TrimSpace(contentType)
+}
+
+func validate(obj interface{}) error {
+	if Validator == nil {
+		return nil
+	}
+	return Validator.ValidateStruct(obj)
+}
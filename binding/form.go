@@ -0,0 +1,93 @@
+package binding
+
+import (
+	"mime/multipart"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+type formBinding struct{}
+type queryBinding struct{}
+type formPostBinding struct{}
+type formMultipartBinding struct{}
+
+func (formBinding) Name() string {
+	return "form"
+}
+
+// Bind 解析query和body中的表单, multipart请求同时解析文件
+func (b formBinding) Bind(req *http.Request, obj interface{}) error {
+	return b.BindMaxMemory(req, obj, defaultMaxMemory)
+}
+
+// BindMaxMemory 解析multipart时最多使用maxMemory字节的内存, 不大于0时使用默认值
+func (formBinding) BindMaxMemory(req *http.Request, obj interface{}, maxMemory int64) error {
+	if maxMemory <= 0 {
+		maxMemory = defaultMaxMemory
+	}
+	if err := req.ParseForm(); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := req.ParseMultipartForm(maxMemory); err != nil && err != http.ErrNotMultipart {
+		return errors.WithStack(err)
+	}
+	var files map[string][]*multipart.FileHeader
+	if req.MultipartForm != nil {
+		files = req.MultipartForm.File
+	}
+	if err := mapForm(obj, req.Form, files); err != nil {
+		return err
+	}
+	return validate(obj)
+}
+
+func (queryBinding) Name() string {
+	return "query"
+}
+
+// Bind 只解析url中的query
+func (queryBinding) Bind(req *http.Request, obj interface{}) error {
+	if err := mapForm(obj, req.URL.Query(), nil); err != nil {
+		return err
+	}
+	return validate(obj)
+}
+
+func (formPostBinding) Name() string {
+	return "form-urlencoded"
+}
+
+// Bind 只解析body中的表单
+func (formPostBinding) Bind(req *http.Request, obj interface{}) error {
+	if err := req.ParseForm(); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := mapForm(obj, req.PostForm, nil); err != nil {
+		return err
+	}
+	return validate(obj)
+}
+
+func (formMultipartBinding) Name() string {
+	return "multipart/form-data"
+}
+
+// Bind 解析multipart表单和上传的文件
+func (b formMultipartBinding) Bind(req *http.Request, obj interface{}) error {
+	return b.BindMaxMemory(req, obj, defaultMaxMemory)
+}
+
+// BindMaxMemory 解析multipart时最多使用maxMemory字节的内存, 不大于0时使用默认值
+func (formMultipartBinding) BindMaxMemory(req *http.Request, obj interface{}, maxMemory int64) error {
+	if maxMemory <= 0 {
+		maxMemory = defaultMaxMemory
+	}
+	if err := req.ParseMultipartForm(maxMemory); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := mapForm(obj, req.MultipartForm.Value, req.MultipartForm.File); err != nil {
+		return err
+	}
+	return validate(obj)
+}
@@ -0,0 +1,200 @@
+package binding
+
+import (
+	"mime/multipart"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	_fileHeaderType      = reflect.TypeOf((*multipart.FileHeader)(nil))
+	_fileHeaderSliceType = reflect.TypeOf([]*multipart.FileHeader(nil))
+	_timeType            = reflect.TypeOf(time.Time{})
+	_durationType        = reflect.TypeOf(time.Duration(0))
+)
+
+// mapForm 根据form标签把表单数据和上传的文件映射到结构体中
+// 支持的标签:
+//
+//	form:"name"        表单中的key, 不设置时使用字段名
+//	form:"ids,split"   把 1,2,3 这样的值拆分到slice中
+//	default:"1"        表单中不存在时使用的默认值
+//	time_format:"2006-01-02" time.Time的解析格式, 默认RFC3339
+func mapForm(ptr interface{}, form map[string][]string, files map[string][]*multipart.FileHeader) error {
+	rv := reflect.ValueOf(ptr)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return errors.Errorf("binding: mapForm(non-pointer %T)", ptr)
+	}
+	rv = rv.Elem()
+	if rv.Kind() != reflect.Struct {
+		return errors.Errorf("binding: mapForm(non-struct pointer %T)", ptr)
+	}
+	return mapStruct(rv, form, files)
+}
+
+func mapStruct(rv reflect.Value, form map[string][]string, files map[string][]*multipart.FileHeader) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		fv := rv.Field(i)
+		if !fv.CanSet() {
+			continue
+		}
+		tag := field.Tag.Get("form")
+		if tag == "-" {
+			continue
+		}
+		if tag == "" && fv.Kind() == reflect.Struct && field.Type != _timeType {
+			// 没有form标签的结构体递归处理
+			if err := mapStruct(fv, form, files); err != nil {
+				return err
+			}
+			continue
+		}
+		name, opts := tag, ""
+		if idx := strings.IndexByte(tag, ','); idx >= 0 {
+			name, opts = tag[:idx], tag[idx+1:]
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		// 上传的文件
+		switch field.Type {
+		case _fileHeaderType:
+			if fhs := files[name]; len(fhs) > 0 {
+				fv.Set(reflect.ValueOf(fhs[0]))
+			}
+			continue
+		case _fileHeaderSliceType:
+			if fhs := files[name]; len(fhs) > 0 {
+				fv.Set(reflect.ValueOf(fhs))
+			}
+			continue
+		}
+
+		vals, ok := form[name]
+		if !ok || len(vals) == 0 {
+			def, hasDef := field.Tag.Lookup("default")
+			if !hasDef {
+				continue
+			}
+			vals = []string{def}
+		}
+		if opts == "split" && len(vals) == 1 {
+			vals = strings.Split(vals[0], ",")
+		}
+		if err := setField(fv, field, vals); err != nil {
+			return errors.Wrapf(err, "binding: field %s with %s=%v", field.Name, name, vals)
+		}
+	}
+	return nil
+}
+
+func setField(fv reflect.Value, field reflect.StructField, vals []string) error {
+	switch fv.Kind() {
+	case reflect.Slice:
+		slice := reflect.MakeSlice(fv.Type(), len(vals), len(vals))
+		for i, val := range vals {
+			if err := setValue(slice.Index(i), field, val); err != nil {
+				return err
+			}
+		}
+		fv.Set(slice)
+		return nil
+	case reflect.Array:
+		if len(vals) != fv.Len() {
+			return errors.Errorf("%q is not valid value for %s", vals, fv.Type())
+		}
+		for i, val := range vals {
+			if err := setValue(fv.Index(i), field, val); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return setValue(fv, field, vals[0])
+}
+
+func setValue(fv reflect.Value, field reflect.StructField, val string) error {
+	switch fv.Type() {
+	case _timeType:
+		return setTime(fv, field, val)
+	case _durationType:
+		d, err := time.ParseDuration(val)
+		if err != nil {
+			return err
+		}
+		fv.SetInt(int64(d))
+		return nil
+	}
+	switch fv.Kind() {
+	case reflect.Ptr:
+		if fv.IsNil() {
+			fv.Set(reflect.New(fv.Type().Elem()))
+		}
+		return setValue(fv.Elem(), field, val)
+	case reflect.String:
+		fv.SetString(val)
+	case reflect.Bool:
+		if val == "" {
+			val = "false"
+		}
+		b, err := strconv.ParseBool(val)
+		if err != nil {
+			return err
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if val == "" {
+			val = "0"
+		}
+		i, err := strconv.ParseInt(val, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if val == "" {
+			val = "0"
+		}
+		u, err := strconv.ParseUint(val, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		if val == "" {
+			val = "0.0"
+		}
+		f, err := strconv.ParseFloat(val, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetFloat(f)
+	default:
+		return errors.Errorf("unsupported type %s", fv.Type())
+	}
+	return nil
+}
+
+func setTime(fv reflect.Value, field reflect.StructField, val string) error {
+	if val == "" {
+		fv.Set(reflect.ValueOf(time.Time{}))
+		return nil
+	}
+	layout := field.Tag.Get("time_format")
+	if layout == "" {
+		layout = time.RFC3339
+	}
+	t, err := time.ParseInLocation(layout, val, time.Local)
+	if err != nil {
+		return err
+	}
+	fv.Set(reflect.ValueOf(t))
+	return nil
+}
@@ -0,0 +1,58 @@
+package binding
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+type uploadArg struct {
+	Name string                `form:"name" validate:"required"`
+	File *multipart.FileHeader `form:"file"`
+}
+
+func multipartRequest(t *testing.T) *http.Request {
+	body := &bytes.Buffer{}
+	w := multipart.NewWriter(body)
+	w.WriteField("name", "pudding")
+	fw, err := w.CreateFormFile("file", "a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write(bytes.Repeat([]byte("x"), 1024))
+	w.Close()
+	req := httptest.NewRequest(http.MethodPost, "/upload", body)
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	return req
+}
+
+func TestMultipartBindMaxMemory(t *testing.T) {
+	for _, b := range []MultipartBinding{Form, FormMultipart} {
+		req := multipartRequest(t)
+		arg := &uploadArg{}
+		// 超过maxMemory的文件写入临时文件
+		if err := b.BindMaxMemory(req, arg, 1); err != nil {
+			t.Fatalf("%s: %v", b.Name(), err)
+		}
+		if arg.Name != "pudding" || arg.File == nil {
+			t.Fatalf("%s: %+v", b.Name(), arg)
+		}
+		f, err := arg.File.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := f.(*os.File); !ok {
+			t.Errorf("%s: file larger than maxMemory is kept in memory", b.Name())
+		}
+		n, _ := io.Copy(io.Discard, f)
+		f.Close()
+		if n != 1024 {
+			t.Errorf("%s: file size %d", b.Name(), n)
+		}
+		req.MultipartForm.RemoveAll()
+	}
+}
@@ -0,0 +1,258 @@
+package binding
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+// 校验规则, 写在validate标签中, 多个规则用逗号分隔, 例如:
+//
+//	Name string `form:"name" validate:"required,min=1,max=32"`
+//	Type string `form:"type" validate:"omitempty,enum=a|b|c"`
+//	Mail string `form:"mail" validate:"regex=^[a-z]+@[a-z.]+$"`
+//
+// regex 必须是最后一条规则, 正则中可以包含逗号
+const (
+	_ruleOmitEmpty = "omitempty"
+	_ruleRequired  = "required"
+	_ruleMin       = "min"
+	_ruleMax       = "max"
+	_ruleRegex     = "regex"
+	_ruleEnum      = "enum"
+)
+
+// FieldError is the error of a field which failed the validation
+type FieldError struct {
+	// Field 字段在结构体中的路径, 例如 User.Name
+	Field string `json:"field"`
+	// Rule 未通过的规则, 例如 required, min
+	Rule string `json:"rule"`
+	// Param 规则的参数, 例如 min=1 中的 1
+	Param string      `json:"param,omitempty"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// Error implement error interface
+func (fe *FieldError) Error() string {
+	if fe.Param == "" {
+		return fmt.Sprintf("field %s failed on the '%s' rule", fe.Field, fe.Rule)
+	}
+	return fmt.Sprintf("field %s failed on the '%s=%s' rule", fe.Field, fe.Rule, fe.Param)
+}
+
+// ValidationErrors is the structured error returned when the validation failed
+type ValidationErrors []*FieldError
+
+// Error implement error interface
+func (ve ValidationErrors) Error() string {
+	msgs := make([]string, 0, len(ve))
+	for _, fe := range ve {
+		msgs = append(msgs, fe.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+type defaultValidator struct {
+	// 编译后的正则缓存
+	regexps sync.Map
+}
+
+var _ StructValidator = &defaultValidator{}
+
+// ValidateStruct validates the struct by validate tags
+func (v *defaultValidator) ValidateStruct(obj interface{}) error {
+	rv := reflect.ValueOf(obj)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+	var errs ValidationErrors
+	if err := v.validateStruct(rv, "", &errs); err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (v *defaultValidator) validateStruct(rv reflect.Value, namespace string, errs *ValidationErrors) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+		fv := rv.Field(i)
+		name := namespace + field.Name
+		if tag := field.Tag.Get("validate"); tag != "" && tag != "-" {
+			if err := v.validateField(fv, name, tag, errs); err != nil {
+				return err
+			}
+		}
+		// 嵌套的结构体递归校验
+		for fv.Kind() == reflect.Ptr && !fv.IsNil() {
+			fv = fv.Elem()
+		}
+		if fv.Kind() == reflect.Struct && fv.Type() != _timeType {
+			if err := v.validateStruct(fv, name+".", errs); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v *defaultValidator) validateField(fv reflect.Value, name, tag string, errs *ValidationErrors) error {
+	rules := splitRules(tag)
+	zero := isZero(fv)
+	for _, rule := range rules {
+		key, param := rule, ""
+		if idx := strings.IndexByte(rule, '='); idx >= 0 {
+			key, param = strings.TrimSpace(rule[:idx]), strings.TrimSpace(rule[idx+1:])
+		}
+		if key == _ruleOmitEmpty {
+			if zero {
+				return nil
+			}
+			continue
+		}
+		if key == _ruleRequired {
+			if zero {
+				*errs = append(*errs, &FieldError{Field: name, Rule: key})
+				return nil
+			}
+			continue
+		}
+		// 空指针只校验required
+		val := fv
+		for val.Kind() == reflect.Ptr {
+			if val.IsNil() {
+				return nil
+			}
+			val = val.Elem()
+		}
+		ok, err := v.check(val, key, param)
+		if err != nil {
+			return errors.Wrapf(err, "binding: invalid validate tag %q on field %s", tag, name)
+		}
+		if !ok {
+			fe := &FieldError{Field: name, Rule: key, Param: param}
+			if val.CanInterface() {
+				fe.Value = val.Interface()
+			}
+			*errs = append(*errs, fe)
+			return nil
+		}
+	}
+	return nil
+}
+
+func (v *defaultValidator) check(val reflect.Value, key, param string) (bool, error) {
+	switch key {
+	case _ruleMin, _ruleMax:
+		limit, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			return false, err
+		}
+		n, ok := measure(val)
+		if !ok {
+			return false, errors.Errorf("rule %s unsupported on %s", key, val.Type())
+		}
+		if key == _ruleMin {
+			return n >= limit, nil
+		}
+		return n <= limit, nil
+	case _ruleRegex:
+		if val.Kind() != reflect.String {
+			return false, errors.Errorf("rule %s unsupported on %s", key, val.Type())
+		}
+		re, err := v.regexp(param)
+		if err != nil {
+			return false, err
+		}
+		return re.MatchString(val.String()), nil
+	case _ruleEnum:
+		str := fmt.Sprint(val.Interface())
+		for _, item := range strings.Split(param, "|") {
+			if item == str {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	return false, errors.Errorf("unknown rule %s", key)
+}
+
+func (v *defaultValidator) regexp(pattern string) (*regexp.Regexp, error) {
+	if re, ok := v.regexps.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	v.regexps.Store(pattern, re)
+	return re, nil
+}
+
+// splitRules 按逗号拆分规则, regex之后的内容都属于正则
+func splitRules(tag string) []string {
+	var rules []string
+	for tag != "" {
+		// 逗号后面可以有空格, 例如 "required, min=2"
+		tag = strings.TrimLeft(tag, " \t")
+		if strings.HasPrefix(tag, _ruleRegex+"=") {
+			return append(rules, strings.TrimSpace(tag))
+		}
+		idx := strings.IndexByte(tag, ',')
+		if idx < 0 {
+			idx = len(tag)
+		}
+		if rule := strings.TrimSpace(tag[:idx]); rule != "" {
+			rules = append(rules, rule)
+		}
+		if idx == len(tag) {
+			break
+		}
+		tag = tag[idx+1:]
+	}
+	return rules
+}
+
+// measure 数字取值, 字符串取字符数, slice/map/array取长度
+func measure(val reflect.Value) (float64, bool) {
+	switch val.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(val.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(val.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return val.Float(), true
+	case reflect.String:
+		return float64(utf8.RuneCountInString(val.String())), true
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return float64(val.Len()), true
+	}
+	return 0, false
+}
+
+func isZero(fv reflect.Value) bool {
+	switch fv.Kind() {
+	case reflect.Slice, reflect.Map:
+		return fv.Len() == 0
+	}
+	return fv.IsZero()
+}
@@ -0,0 +1,96 @@
+package binding
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestSplitRules(t *testing.T) {
+	cases := []struct {
+		tag  string
+		want []string
+	}{
+		{"required", []string{"required"}},
+		{"required,min=2", []string{"required", "min=2"}},
+		{"required, min=2", []string{"required", "min=2"}},
+		{" required , min=2 ,max=3 ", []string{"required", "min=2", "max=3"}},
+		{"omitempty,,enum=a|b", []string{"omitempty", "enum=a|b"}},
+		{"required, regex=^[a-z]{1,3}$", []string{"required", "regex=^[a-z]{1,3}$"}},
+		{"regex=^a,b$", []string{"regex=^a,b$"}},
+	}
+	for _, cs := range cases {
+		if got := splitRules(cs.tag); !reflect.DeepEqual(got, cs.want) {
+			t.Errorf("splitRules(%q) = %q, want %q", cs.tag, got, cs.want)
+		}
+	}
+}
+
+type validateBase struct {
+	ID int `validate:"min=1"`
+}
+
+type validateInner struct {
+	Code string `validate:"enum=a|b"`
+}
+
+type validateArg struct {
+	validateBase
+	Name  string `validate:"required, min=2, max=4"`
+	Type  string `validate:"omitempty, enum=x|y"`
+	Mail  string `validate:"omitempty, regex=^[a-z]+@[a-z]+\\.com$"`
+	Inner validateInner
+	Ptr   *validateInner `validate:"required"`
+}
+
+func TestValidateStruct(t *testing.T) {
+	valid := func() *validateArg {
+		return &validateArg{
+			validateBase: validateBase{ID: 1},
+			Name:         "abc",
+			Type:         "x",
+			Mail:         "a@b.com",
+			Inner:        validateInner{Code: "a"},
+			Ptr:          &validateInner{Code: "b"},
+		}
+	}
+	cases := []struct {
+		name   string
+		modify func(a *validateArg)
+		field  string
+		rule   string
+	}{
+		{"valid", func(a *validateArg) {}, "", ""},
+		{"required", func(a *validateArg) { a.Name = "" }, "Name", "required"},
+		{"min with space", func(a *validateArg) { a.Name = "a" }, "Name", "min"},
+		{"max with space", func(a *validateArg) { a.Name = "abcde" }, "Name", "max"},
+		{"omitempty enum", func(a *validateArg) { a.Type = "" }, "", ""},
+		{"enum", func(a *validateArg) { a.Type = "z" }, "Type", "enum"},
+		{"regex after space", func(a *validateArg) { a.Mail = "a@b.org" }, "Mail", "regex"},
+		{"embedded", func(a *validateArg) { a.ID = 0 }, "validateBase.ID", "min"},
+		{"nested", func(a *validateArg) { a.Inner.Code = "c" }, "Inner.Code", "enum"},
+		{"nil pointer", func(a *validateArg) { a.Ptr = nil }, "Ptr", "required"},
+		{"nested pointer", func(a *validateArg) { a.Ptr.Code = "c" }, "Ptr.Code", "enum"},
+	}
+	v := &defaultValidator{}
+	for _, cs := range cases {
+		arg := valid()
+		cs.modify(arg)
+		err := v.ValidateStruct(arg)
+		if cs.field == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error %v", cs.name, err)
+			}
+			continue
+		}
+		var errs ValidationErrors
+		if !errors.As(err, &errs) || len(errs) != 1 {
+			t.Errorf("%s: got %v, want one field error", cs.name, err)
+			continue
+		}
+		if errs[0].Field != cs.field || errs[0].Rule != cs.rule {
+			t.Errorf("%s: got %s/%s, want %s/%s", cs.name, errs[0].Field, errs[0].Rule, cs.field, cs.rule)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package pudding
+
+import (
+	"net/http"
+
+	"github.com/bdjimmy/pudding/breaker"
+	"github.com/bdjimmy/pudding/ecode"
+)
+
+// Breaker sheds the load of the failing routes with the breakers keyed by the route,
+// the rejected requests get ecode.ServiceUnavailable. The server errors, timeouts and
+// rejections by the limiters are counted as failures, the other business errors are not.
+// A panicking handler is counted as a failure before the panic reaches Recovery
+// 按路由熔断, 服务端错误、超时和限流算作失败, 熔断时返回ecode.ServiceUnavailable
+func Breaker(g *breaker.Group) HandlerFunc {
+	return func(c *Context) {
+		path := c.RoutePath
+		if path == "" {
+			path = _metricUnmatchedPath
+		}
+		brk := g.Get(path)
+		if err := brk.Allow(); err != nil {
+			c.JSON(nil, ecode.Wrap(ecode.ServiceUnavailable, err))
+			c.Abort()
+			return
+		}
+		// 处理函数panic时failed保持为true, 在defer中上报, 半开状态的探测请求不会一直占用
+		failed := true
+		defer func() {
+			if failed {
+				brk.MarkFailed()
+				return
+			}
+			brk.MarkSuccess()
+		}()
+		c.Next()
+		failed = breakerFailed(c.Error)
+		if rw, ok := c.Writer.(ResponseWriter); ok && rw.Status() >= http.StatusInternalServerError {
+			failed = true
+		}
+	}
+}
+
+// breakerFailed 判断错误是否算作熔断的失败
+func breakerFailed(err error) bool {
+	switch ecode.Cause(err).Code() {
+	case ecode.ServerErr.Code(), ecode.ServiceUnavailable.Code(), ecode.Deadline.Code(), ecode.LimitExceed.Code():
+		return true
+	}
+	return false
+}
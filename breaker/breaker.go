@@ -0,0 +1,168 @@
+// Package breaker provides the circuit breakers failing fast when a downstream is failing:
+// an SRE-style adaptive breaker and a classic closed/open/half-open breaker, keyed by target
+package breaker
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrNotAllowed is returned by Allow if the breaker rejects the request
+var ErrNotAllowed = errors.New("breaker: not allowed")
+
+// State is the state of a breaker
+type State int32
+
+// breaker states
+const (
+	StateClosed State = iota
+	StateOpen
+	StateHalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// Breaker decides whether a request is allowed, the result of the allowed request
+// must be reported by MarkSuccess or MarkFailed
+// 请求被允许后需要通过MarkSuccess或MarkFailed上报结果
+type Breaker interface {
+	Allow() error
+	MarkSuccess()
+	MarkFailed()
+}
+
+// Observer is called when the state of the breaker named name changes
+type Observer func(name string, from, to State)
+
+// Config is the config of the breakers
+type Config struct {
+	// Window 统计请求结果的滑动窗口, 默认3s
+	Window time.Duration
+	// Buckets 窗口内的桶个数, 默认10
+	Buckets int
+	// Request 窗口内的请求数少于Request时不熔断, 默认100
+	Request int64
+
+	// K SRE熔断器的倍率, 请求数超过成功数的K倍时开始按概率拒绝, 越小越激进, 默认1.5
+	K float64
+
+	// Ratio 经典熔断器的失败率阈值, 超过时打开, 默认0.5
+	Ratio float64
+	// Sleep 经典熔断器打开后经过Sleep进入半开状态, 默认5s
+	Sleep time.Duration
+	// Probe 半开状态允许的探测请求数, 全部成功后关闭, 默认1
+	Probe int64
+	// ProbeTimeout 探测请求超过ProbeTimeout没有上报结果时视为丢失, 重新放行探测请求, 默认为Sleep
+	ProbeTimeout time.Duration
+
+	// OnStateChange 状态变化的回调, 在持有熔断器锁之外调用
+	OnStateChange Observer
+}
+
+// fix 填充默认值
+func (c *Config) fix() {
+	if c.Window <= 0 {
+		c.Window = 3 * time.Second
+	}
+	if c.Buckets <= 0 {
+		c.Buckets = 10
+	}
+	if c.Request <= 0 {
+		c.Request = 100
+	}
+	if c.K <= 0 {
+		c.K = 1.5
+	}
+	if c.Ratio <= 0 {
+		c.Ratio = 0.5
+	}
+	if c.Sleep <= 0 {
+		c.Sleep = 5 * time.Second
+	}
+	if c.Probe <= 0 {
+		c.Probe = 1
+	}
+	if c.ProbeTimeout <= 0 {
+		c.ProbeTimeout = c.Sleep
+	}
+}
+
+// notify 通知状态变化
+func (c *Config) notify(name string, from, to State) {
+	if from != to && c.OnStateChange != nil {
+		c.OnStateChange(name, from, to)
+	}
+}
+
+// Group is a set of breakers keyed by the target, e.g. the host of the downstream,
+// the breaker of a target is created on the first use
+// 按目标区分的熔断器, 首次使用时创建
+type Group struct {
+	conf Config
+	new  func(name string, conf *Config) Breaker
+
+	mu       sync.RWMutex
+	breakers map[string]Breaker
+}
+
+// NewGroup new a breaker group creating the breakers with new, e.g. NewSRE or NewClassic,
+// conf can be nil and new defaults to NewSRE
+func NewGroup(conf *Config, new func(name string, conf *Config) Breaker) *Group {
+	g := &Group{new: new, breakers: make(map[string]Breaker)}
+	if conf != nil {
+		g.conf = *conf
+	}
+	if g.new == nil {
+		g.new = func(name string, conf *Config) Breaker {
+			return NewSRE(name, conf)
+		}
+	}
+	return g
+}
+
+// Get returns the breaker of the target
+func (g *Group) Get(name string) Breaker {
+	g.mu.RLock()
+	b, ok := g.breakers[name]
+	g.mu.RUnlock()
+	if ok {
+		return b
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if b, ok = g.breakers[name]; ok {
+		return b
+	}
+	conf := g.conf
+	b = g.new(name, &conf)
+	g.breakers[name] = b
+	return b
+}
+
+// Do runs fn if the breaker of the target allows, the error of fn is reported as a failure
+// if failed returns true, failed defaults to err != nil
+func (g *Group) Do(name string, fn func() error, failed func(err error) bool) error {
+	b := g.Get(name)
+	if err := b.Allow(); err != nil {
+		return err
+	}
+	err := fn()
+	if failed == nil && err != nil || failed != nil && failed(err) {
+		b.MarkFailed()
+	} else {
+		b.MarkSuccess()
+	}
+	return err
+}
@@ -0,0 +1,109 @@
+package breaker
+
+import (
+	"sync"
+	"time"
+)
+
+// classic 经典熔断器: 关闭时统计失败率, 超过阈值后打开, 经过Sleep后半开放行探测请求,
+// 探测请求全部成功后关闭, 任意一个失败重新打开
+type classic struct {
+	name string
+	conf *Config
+
+	mu    sync.Mutex
+	stat  *window
+	state State
+	// openAt 打开的时间
+	openAt time.Time
+	// 半开状态已经放行和成功的探测请求数, 以及最近一次放行探测请求的时间
+	probes    int64
+	successes int64
+	probeAt   time.Time
+}
+
+// NewClassic new a closed/open/half-open breaker named name, conf can be nil.
+// It opens when the failure ratio in the window exceeds conf.Ratio with at least conf.Request requests,
+// turns half-open after conf.Sleep and allows conf.Probe requests, then closes if all of them succeed.
+// The probes without result in conf.ProbeTimeout are considered lost and new probes are allowed
+func NewClassic(name string, conf *Config) Breaker {
+	c := Config{}
+	if conf != nil {
+		c = *conf
+	}
+	c.fix()
+	return &classic{
+		name: name,
+		conf: &c,
+		stat: newWindow(c.Window, c.Buckets),
+	}
+}
+
+func (b *classic) Allow() error {
+	now := time.Now()
+	b.mu.Lock()
+	from := b.state
+	if b.state == StateOpen && now.Sub(b.openAt) >= b.conf.Sleep {
+		b.state = StateHalfOpen
+		b.probes, b.successes = 0, 0
+	}
+	var err error
+	switch b.state {
+	case StateOpen:
+		err = ErrNotAllowed
+	case StateHalfOpen:
+		// 探测请求超时没有上报结果时, 例如调用方没有调用Mark, 重新放行探测请求
+		if b.probes >= b.conf.Probe && now.Sub(b.probeAt) >= b.conf.ProbeTimeout {
+			b.probes, b.successes = 0, 0
+		}
+		if b.probes >= b.conf.Probe {
+			err = ErrNotAllowed
+		} else {
+			b.probes++
+			b.probeAt = now
+		}
+	}
+	to := b.state
+	b.mu.Unlock()
+	b.conf.notify(b.name, from, to)
+	return err
+}
+
+func (b *classic) MarkSuccess() {
+	now := time.Now()
+	b.mu.Lock()
+	from := b.state
+	switch b.state {
+	case StateClosed:
+		b.stat.add(now, true)
+	case StateHalfOpen:
+		if b.successes++; b.successes >= b.conf.Probe {
+			b.state = StateClosed
+			b.stat.reset(now)
+		}
+	}
+	to := b.state
+	b.mu.Unlock()
+	b.conf.notify(b.name, from, to)
+}
+
+func (b *classic) MarkFailed() {
+	now := time.Now()
+	b.mu.Lock()
+	from := b.state
+	switch b.state {
+	case StateClosed:
+		b.stat.add(now, false)
+		total, success := b.stat.sum(now)
+		if total >= b.conf.Request && float64(total-success) >= b.conf.Ratio*float64(total) {
+			b.state = StateOpen
+			b.openAt = now
+		}
+	case StateHalfOpen:
+		b.state = StateOpen
+		b.openAt = now
+	}
+	to := b.state
+	b.mu.Unlock()
+	b.conf.notify(b.name, from, to)
+}
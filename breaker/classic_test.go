@@ -0,0 +1,33 @@
+package breaker
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClassicProbeTimeout(t *testing.T) {
+	b := NewClassic("test", &Config{Request: 1, Sleep: 10 * time.Millisecond, ProbeTimeout: 20 * time.Millisecond})
+	if err := b.Allow(); err != nil {
+		t.Fatal(err)
+	}
+	b.MarkFailed()
+	if err := b.Allow(); err != ErrNotAllowed {
+		t.Fatalf("open breaker allowed: %v", err)
+	}
+	time.Sleep(15 * time.Millisecond)
+	// 半开状态的探测请求没有上报结果
+	if err := b.Allow(); err != nil {
+		t.Fatalf("probe rejected: %v", err)
+	}
+	if err := b.Allow(); err != ErrNotAllowed {
+		t.Fatalf("second probe allowed: %v", err)
+	}
+	time.Sleep(25 * time.Millisecond)
+	if err := b.Allow(); err != nil {
+		t.Fatalf("probe after timeout rejected: %v", err)
+	}
+	b.MarkSuccess()
+	if err := b.Allow(); err != nil {
+		t.Fatalf("closed breaker rejected: %v", err)
+	}
+}
@@ -0,0 +1,75 @@
+package breaker
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// sre is the adaptive breaker described in Google SRE "Handling Overload":
+// the requests are rejected locally with the probability max(0, (requests - K * accepts) / (requests + 1)),
+// the rejected requests are counted as the requests, so the probability drops as the downstream recovers
+// 自适应熔断: 按 max(0, (requests - K * accepts) / (requests + 1)) 的概率在本地拒绝请求
+type sre struct {
+	name string
+	conf *Config
+
+	mu     sync.Mutex
+	stat   *window
+	state  State
+	random *rand.Rand
+}
+
+// NewSRE new an adaptive breaker named name, conf can be nil.
+// It's open while the rejecting probability is positive, there is no half-open state
+func NewSRE(name string, conf *Config) Breaker {
+	c := Config{}
+	if conf != nil {
+		c = *conf
+	}
+	c.fix()
+	return &sre{
+		name:   name,
+		conf:   &c,
+		stat:   newWindow(c.Window, c.Buckets),
+		random: rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+func (b *sre) Allow() error {
+	now := time.Now()
+	b.mu.Lock()
+	total, success := b.stat.sum(now)
+	p := 0.0
+	if total >= b.conf.Request {
+		p = math.Max(0, (float64(total)-b.conf.K*float64(success))/float64(total+1))
+	}
+	from, to := b.state, StateClosed
+	if p > 0 {
+		to = StateOpen
+	}
+	drop := b.random.Float64() < p
+	if drop {
+		b.stat.add(now, false)
+	}
+	b.state = to
+	b.mu.Unlock()
+	b.conf.notify(b.name, from, to)
+	if drop {
+		return ErrNotAllowed
+	}
+	return nil
+}
+
+func (b *sre) MarkSuccess() {
+	b.mu.Lock()
+	b.stat.add(time.Now(), true)
+	b.mu.Unlock()
+}
+
+func (b *sre) MarkFailed() {
+	b.mu.Lock()
+	b.stat.add(time.Now(), false)
+	b.mu.Unlock()
+}
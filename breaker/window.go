@@ -0,0 +1,69 @@
+package breaker
+
+import (
+	"time"
+)
+
+// bucket 一个桶内的请求数和成功数
+type bucket struct {
+	total   int64
+	success int64
+}
+
+// window 按时间滑动的计数窗口, 调用者需要加锁
+type window struct {
+	buckets []bucket
+	size    time.Duration
+	// 当前桶的下标和开始时间
+	offset int
+	start  time.Time
+}
+
+func newWindow(d time.Duration, buckets int) *window {
+	size := d / time.Duration(buckets)
+	if size <= 0 {
+		size = time.Millisecond
+	}
+	return &window{buckets: make([]bucket, buckets), size: size, start: time.Now()}
+}
+
+func (w *window) add(now time.Time, success bool) {
+	b := w.current(now)
+	b.total++
+	if success {
+		b.success++
+	}
+}
+
+// sum 返回窗口内的请求数和成功数
+func (w *window) sum(now time.Time) (total, success int64) {
+	w.current(now)
+	for _, b := range w.buckets {
+		total += b.total
+		success += b.success
+	}
+	return
+}
+
+func (w *window) reset(now time.Time) {
+	for i := range w.buckets {
+		w.buckets[i] = bucket{}
+	}
+	w.start = now
+}
+
+// current 滑动到now所在的桶并清空跳过的桶
+func (w *window) current(now time.Time) *bucket {
+	steps := int(now.Sub(w.start) / w.size)
+	if steps > len(w.buckets) {
+		steps = len(w.buckets)
+	}
+	for i := 0; i < steps; i++ {
+		w.offset = (w.offset + 1) % len(w.buckets)
+		w.buckets[w.offset] = bucket{}
+	}
+	if steps > 0 {
+		w.start = w.start.Add(now.Sub(w.start) / w.size * w.size)
+	}
+	return &w.buckets[w.offset]
+}
@@ -0,0 +1,41 @@
+package pudding
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bdjimmy/pudding/breaker"
+)
+
+func TestBreakerPanicIsFailure(t *testing.T) {
+	engine := New()
+	engine.UseFunc(Recovery())
+	conf := &breaker.Config{Request: 1, Sleep: 10 * time.Millisecond}
+	panicking := true
+	engine.GET("/b", Breaker(breaker.NewGroup(conf, breaker.NewClassic)), func(c *Context) {
+		if panicking {
+			panic("boom")
+		}
+		c.String(http.StatusOK, "ok")
+	})
+	serve := func() string {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/b", nil))
+		return w.Body.String()
+	}
+	serve()
+	if body := serve(); !strings.Contains(body, "-503") {
+		t.Fatalf("breaker not open after panic: %s", body)
+	}
+	time.Sleep(15 * time.Millisecond)
+	// 半开状态的探测请求panic, 重新打开
+	serve()
+	time.Sleep(15 * time.Millisecond)
+	panicking = false
+	if body := serve(); body != "ok" {
+		t.Fatalf("probe after panicking probe: %s", body)
+	}
+}
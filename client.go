@@ -0,0 +1,360 @@
+package pudding
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/bdjimmy/pudding/auth"
+	"github.com/bdjimmy/pudding/breaker"
+	"github.com/bdjimmy/pudding/ecode"
+	"github.com/bdjimmy/pudding/metadata"
+	"github.com/bdjimmy/pudding/trace"
+	"github.com/bdjimmy/pudding/utils"
+	"github.com/pkg/errors"
+)
+
+const (
+	_minRead = 16 * 1024 // 16kb
+	// _maxErrorBody 状态码>=400时最多读取的响应体
+	_maxErrorBody = 64 * 1024 // 64kb
+
+	_contentTypeForm = "application/x-www-form-urlencoded"
+)
+
+// ClientConfig is the pudding http client config model
+type ClientConfig struct {
+	// AppID 当前服务的标识, 作为调用方发送给下游, 为空时透传上游的调用方
+	AppID     string
+	Dial      utils.Duration
+	Timeout   utils.Duration
+	KeepAlive utils.Duration
+	// Breaker 按下游的host熔断, 为空时不熔断, 参见 Client.SetBreaker
+	Breaker *breaker.Config
+	// Key Secret 不为空时使用appkey签名请求参数, 服务端通过SignAuth校验
+	Key    string
+	Secret string
+}
+
+// Client is the http client which propagates the pudding metadata and deadline to the server
+// 调用其他pudding服务的http客户端, 会透传metadata和剩余的超时时间
+type Client struct {
+	conf     *ClientConfig
+	client   *http.Client
+	breakers *breaker.Group
+}
+
+// NewClient new a http client
+func NewClient(conf *ClientConfig) *Client {
+	if conf == nil {
+		conf = &ClientConfig{
+			Dial:      utils.Duration(time.Second),
+			Timeout:   utils.Duration(time.Second),
+			KeepAlive: utils.Duration(60 * time.Second),
+		}
+	}
+	dialer := &net.Dialer{
+		Timeout:   time.Duration(conf.Dial),
+		KeepAlive: time.Duration(conf.KeepAlive),
+	}
+	transport := &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		DialContext:         dialer.DialContext,
+		MaxIdleConnsPerHost: 100,
+		IdleConnTimeout:     90 * time.Second,
+	}
+	client := &Client{
+		conf:   conf,
+		client: &http.Client{Transport: transport},
+	}
+	if conf.Breaker != nil {
+		client.breakers = breaker.NewGroup(conf.Breaker, nil)
+	}
+	return client
+}
+
+// SetBreaker replaces the breakers keyed by the host of the downstream, e.g. a group of
+// classic breakers, nil disables the breakers. It must be called before sending requests
+// 设置按下游host区分的熔断器, 需要在发送请求前调用
+func (client *Client) SetBreaker(g *breaker.Group) {
+	client.breakers = g
+}
+
+// NewRequest new http request with method, uri, ip and values.
+// GET params are merged into the query of uri, others are encoded into the form body.
+// If the Key of the config is set, the params are signed together with the query of uri,
+// i.e. the same params as the merged form verified by SignAuth on the server
+func (client *Client) NewRequest(method, uri, realIP string, params url.Values) (req *http.Request, err error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		err = errors.Wrapf(err, "method:%s,uri:%s", method, uri)
+		return
+	}
+	query := u.Query()
+	body := params
+	if method == http.MethodGet {
+		body = nil
+		for k, vs := range params {
+			query[k] = append(query[k], vs...)
+		}
+	}
+	if client.conf.Key != "" {
+		query, body = client.sign(method, u.Path, query, body)
+	}
+	u.RawQuery = query.Encode()
+	uri = u.String()
+	if method == http.MethodGet {
+		req, err = http.NewRequest(method, uri, nil)
+	} else {
+		req, err = http.NewRequest(method, uri, strings.NewReader(body.Encode()))
+		if req != nil {
+			req.Header.Set("Content-Type", _contentTypeForm)
+		}
+	}
+	if err != nil {
+		err = errors.Wrapf(err, "method:%s,uri:%s", method, uri)
+		return
+	}
+	if realIP != "" {
+		req.Header.Set(_httpHeaderRemoteIP, realIP)
+	}
+	return
+}
+
+// sign 签名query和body合并后的参数, 和服务端的Request.Form一致: 同名参数body中的值在前.
+// 签名参数放在GET请求的query中, 其他请求的body中
+func (client *Client) sign(method, path string, query, body url.Values) (url.Values, url.Values) {
+	keys := []string{auth.ParamAppKey, auth.ParamTimestamp, auth.ParamSign}
+	query = copyValues(query)
+	body = copyValues(body)
+	for _, k := range keys {
+		query.Del(k)
+		body.Del(k)
+	}
+	merged := copyValues(body)
+	for k, vs := range query {
+		merged[k] = append(merged[k], vs...)
+	}
+	signer := &auth.Signer{Key: client.conf.Key, Secret: client.conf.Secret}
+	signed := signer.Sign(method, path, merged, time.Now())
+	dst := body
+	if method == http.MethodGet {
+		dst = query
+	}
+	for _, k := range keys {
+		dst[k] = signed[k]
+	}
+	return query, body
+}
+
+func copyValues(vs url.Values) url.Values {
+	cp := make(url.Values, len(vs))
+	for k, v := range vs {
+		cp[k] = append([]string(nil), v...)
+	}
+	return cp
+}
+
+// Get issues a GET to the specified URL and decodes the standard JSON envelope into res.
+func (client *Client) Get(ctx context.Context, uri, ip string, params url.Values, res interface{}) (err error) {
+	req, err := client.NewRequest(http.MethodGet, uri, ip, params)
+	if err != nil {
+		return
+	}
+	return client.JSON(ctx, req, res)
+}
+
+// Post issues a POST to the specified URL and decodes the standard JSON envelope into res.
+func (client *Client) Post(ctx context.Context, uri, ip string, params url.Values, res interface{}) (err error) {
+	req, err := client.NewRequest(http.MethodPost, uri, ip, params)
+	if err != nil {
+		return
+	}
+	return client.JSON(ctx, req, res)
+}
+
+// JSON sends the request and decodes the standard render.JSON envelope,
+// the data is decoded into res, and the code is returned as an ecode error if it isn't OK
+// 解析标准的 {code, message, data} 响应, code 不为0时返回对应的ecode错误
+func (client *Client) JSON(ctx context.Context, req *http.Request, res interface{}) (err error) {
+	bs, err := client.Raw(ctx, req)
+	if err != nil {
+		return
+	}
+	var envelope struct {
+		Code    int             `json:"code"`
+		Message string          `json:"message"`
+		Data    json.RawMessage `json:"data"`
+	}
+	if err = json.Unmarshal(bs, &envelope); err != nil {
+		err = errors.Wrapf(err, "host:%s, url:%s", req.URL.Host, req.URL.Path)
+		return
+	}
+	if envelope.Code != ecode.OK.Code() {
+		err = ecode.Error(ecode.Int(envelope.Code), envelope.Message)
+		return
+	}
+	if res != nil && len(envelope.Data) > 0 {
+		if err = json.Unmarshal(envelope.Data, res); err != nil {
+			err = errors.Wrapf(err, "host:%s, url:%s", req.URL.Host, req.URL.Path)
+		}
+	}
+	return
+}
+
+// Raw sends the request and returns the raw body.
+// For the status >= 400 the body is returned with the error, and the error carries
+// the code of the {code, message} envelope if the body has one, so ecode.Cause sees the server code
+// 状态码>=400时返回错误和响应体, 响应体是标准的 {code, message} 时错误中保留服务端的业务码
+func (client *Client) Raw(ctx context.Context, req *http.Request) (bs []byte, err error) {
+	resp, err := client.Do(ctx, req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		err = errors.Errorf("incorrect http status:%d host:%s, url:%s", resp.StatusCode, req.URL.Host, req.URL.Path)
+		// 错误响应体只读取_maxErrorBody, 读取失败时仍然返回状态码错误
+		var rerr error
+		if bs, rerr = readAll(io.LimitReader(resp.Body, _maxErrorBody), _minRead); rerr == nil {
+			if ec := envelopeCode(bs); ec != nil {
+				err = ecode.Wrap(ec, err)
+			}
+		}
+		return
+	}
+	if bs, err = readAll(resp.Body, _minRead); err != nil {
+		err = errors.Wrapf(err, "host:%s, url:%s", req.URL.Host, req.URL.Path)
+	}
+	return
+}
+
+// envelopeCode 解析 {code, message} 中不为0的业务码, 不是标准响应时返回nil
+func envelopeCode(bs []byte) ecode.Codes {
+	var envelope struct {
+		Code    *int   `json:"code"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(bs, &envelope); err != nil || envelope.Code == nil || *envelope.Code == ecode.OK.Code() {
+		return nil
+	}
+	return ecode.Error(ecode.Int(*envelope.Code), envelope.Message)
+}
+
+// Do sends the request with the metadata and the remaining deadline of ctx.
+// The timeout is the minimum of the ctx deadline and the configured timeout
+// 超时时间取ctx剩余时间和配置中的较小值, 通过 x-pudding-timeout 传递给服务端
+func (client *Client) Do(ctx context.Context, req *http.Request) (resp *http.Response, err error) {
+	var cancel func()
+	if to := time.Duration(client.conf.Timeout); to > 0 {
+		ctx, cancel = context.WithTimeout(ctx, to)
+		defer func() {
+			// body读完之前不能取消
+			if err != nil {
+				cancel()
+				return
+			}
+			resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+		}()
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		setTimeout(req, time.Until(deadline))
+	}
+	client.setMetadata(ctx, req)
+	if client.breakers != nil {
+		brk := client.breakers.Get(req.URL.Host)
+		if err = brk.Allow(); err != nil {
+			err = ecode.Wrap(ecode.ServiceUnavailable, errors.Wrapf(err, "host:%s, url:%s", req.URL.Host, req.URL.Path))
+			return
+		}
+		defer func() {
+			// 调用方取消和4xx不算下游的失败
+			if err != nil && !errors.Is(err, context.Canceled) || err == nil && resp.StatusCode >= http.StatusInternalServerError {
+				brk.MarkFailed()
+			} else {
+				brk.MarkSuccess()
+			}
+		}()
+	}
+	// 有server span时创建client span, 并通过traceparent传递给下游
+	if parent, ok := trace.FromContext(ctx); ok {
+		span := parent.Tracer().StartSpan(req.Method+" "+req.URL.Path, trace.KindClient, parent.Context())
+		span.SetTag(trace.TagMethod, req.Method)
+		span.SetTag(trace.TagURL, req.URL.Host+req.URL.Path)
+		trace.Inject(req.Header, span.Context())
+		defer func() {
+			if err != nil {
+				span.SetTag(trace.TagError, err.Error())
+			} else {
+				span.SetTag(trace.TagStatusCode, resp.StatusCode)
+			}
+			span.Finish()
+		}()
+	}
+	req = req.WithContext(ctx)
+	if resp, err = client.client.Do(req); err != nil {
+		// 超时或取消时返回ctx的错误, 便于ecode.Cause识别
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		err = errors.Wrapf(err, "host:%s, url:%s", req.URL.Host, req.URL.Path)
+	}
+	return
+}
+
+// setMetadata 透传调用方、染色和镜像标记
+func (client *Client) setMetadata(ctx context.Context, req *http.Request) {
+	md, _ := metadata.FromContext(ctx)
+	caller := client.conf.AppID
+	if caller == "" {
+		caller, _ = md[metadata.Caller].(string)
+	}
+	if caller != "" {
+		setCaller(req, caller)
+	}
+	if color, _ := md[metadata.Color].(string); color != "" {
+		setColor(req, color)
+	}
+	if metadata.Bool(ctx, metadata.Mirror) {
+		setMirror(req, true)
+	}
+}
+
+// cancelBody 关闭body时取消超时的context
+type cancelBody struct {
+	io.ReadCloser
+	cancel func()
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// readAll reads from r until an error or EOF and returns the data it read
+// from the internal buffer allocated with a specified capacity.
+func readAll(r io.Reader, capacity int64) (b []byte, err error) {
+	buf := bytes.NewBuffer(make([]byte, 0, capacity))
+	// If the buffer overflows, we will get bytes.ErrTooLarge.
+	// Return that as an error. Any other panic remains.
+	defer func() {
+		e := recover()
+		if e == nil {
+			return
+		}
+		if panicErr, ok := e.(error); ok && panicErr == bytes.ErrTooLarge {
+			err = panicErr
+		} else {
+			panic(e)
+		}
+	}()
+	_, err = buf.ReadFrom(r)
+	return buf.Bytes(), err
+}
@@ -0,0 +1,90 @@
+package pudding
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/bdjimmy/pudding/auth"
+	"github.com/bdjimmy/pudding/ecode"
+)
+
+func TestClientSignQuery(t *testing.T) {
+	engine := New()
+	verifier := auth.NewSignVerifier(&auth.SignConfig{Secrets: map[string]string{"app": "secret"}})
+	handler := func(c *Context) { c.String(http.StatusOK, c.Request.Form.Get("a")+c.Request.Form.Get("b")) }
+	engine.GET("/s", SignAuth(verifier), handler)
+	engine.POST("/s", SignAuth(verifier), handler)
+	client := NewClient(&ClientConfig{Key: "app", Secret: "secret"})
+	cases := []struct {
+		method string
+		uri    string
+	}{
+		{http.MethodGet, "/s"},
+		{http.MethodGet, "/s?a=1"},
+		{http.MethodPost, "/s"},
+		{http.MethodPost, "/s?a=1"},
+	}
+	for i, cs := range cases {
+		// 每个请求的参数不同, 避免同一秒内的签名被当作重放
+		params := url.Values{"b": {"2"}, "n": {strconv.Itoa(i)}}
+		if cs.uri == "/s" {
+			params.Set("a", "1")
+		}
+		req, err := client.NewRequest(cs.method, "http://example.com"+cs.uri, "", params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.RemoteAddr = "127.0.0.1:1234"
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		if w.Body.String() != "12" {
+			t.Errorf("%s %s: %d %s", cs.method, cs.uri, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestClientErrorStatus(t *testing.T) {
+	engine := New()
+	engine.SetAuthenticator(auth.ModeJWT, JWTAuth(auth.NewJWTVerifier(nil)))
+	engine.GET("/jwt", func(c *Context) { c.String(http.StatusOK, "ok") })
+	engine.SetMethodConfig("/jwt", &MethodConfig{Auth: auth.ModeJWT})
+	engine.GET("/error", func(c *Context) {
+		c.renderJSON(http.StatusBadRequest, nil, ecode.Error(ecode.RequestErr, "bad name"))
+	})
+	srv := httptest.NewServer(engine)
+	defer srv.Close()
+	client := NewClient(nil)
+
+	cases := []struct {
+		path    string
+		code    int
+		message string
+	}{
+		// 标准响应体的业务码和提示信息保留在错误中
+		{"/jwt", ecode.Unauthorized.Code(), ecode.Unauthorized.Message()},
+		{"/error", ecode.RequestErr.Code(), "bad name"},
+		// 不是标准响应体时只有状态码错误
+		{"/missing", ecode.ServerErr.Code(), ""},
+	}
+	for _, cs := range cases {
+		req, err := client.NewRequest(http.MethodGet, srv.URL+cs.path, "", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bs, err := client.Raw(context.Background(), req)
+		if err == nil || !strings.Contains(err.Error(), "incorrect http status") || len(bs) == 0 {
+			t.Fatalf("%s: %v %s", cs.path, err, bs)
+		}
+		if ec := ecode.Cause(err); ec.Code() != cs.code || cs.message != "" && ec.Message() != cs.message {
+			t.Errorf("%s: cause %d %s, want %d %s", cs.path, ec.Code(), ec.Message(), cs.code, cs.message)
+		}
+		if err = client.JSON(context.Background(), req, nil); ecode.Cause(err).Code() != cs.code {
+			t.Errorf("%s: JSON %v", cs.path, err)
+		}
+	}
+}
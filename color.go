@@ -0,0 +1,63 @@
+package pudding
+
+import (
+	"github.com/bdjimmy/pudding/ecode"
+	"github.com/bdjimmy/pudding/metadata"
+)
+
+// Color returns the color of the request, e.g. the environment or the canary lane,
+// it's passed to the downstream services by the client
+// 返回请求的染色标记, 通过Client调用下游时会继续传递
+func (c *Context) Color() string {
+	return metadata.String(c, metadata.Color)
+}
+
+// IsMirror reports whether the request is mirror (shadow) traffic,
+// the handlers should short-circuit the writes, e.g. database and message queue
+// 镜像流量需要跳过写操作, 例如写数据库和发消息
+func (c *Context) IsMirror() bool {
+	return metadata.Bool(c, metadata.Mirror)
+}
+
+// OnColor returns a middleware which only calls h for the requests with the color
+// 只对带有对应染色标记的请求执行的中间件
+func OnColor(color string, h HandlerFunc) HandlerFunc {
+	return func(c *Context) {
+		if c.Color() == color {
+			h(c)
+		}
+	}
+}
+
+// RejectMirror returns a middleware which rejects the mirror traffic with ecode.AccessDenied,
+// it's used on the routes that can't be sandboxed
+// 拒绝镜像流量, 用于无法隔离写操作的路由
+func RejectMirror() HandlerFunc {
+	return func(c *Context) {
+		if !c.IsMirror() {
+			return
+		}
+		c.JSON(nil, ecode.Error(ecode.AccessDenied, "mirror request rejected"))
+		c.Abort()
+	}
+}
+
+// SandboxMirror returns a middleware which calls the sandbox handlers instead of the rest
+// of the chain for the mirror traffic, an empty response is rendered if no handler is given
+// 镜像流量执行sandbox处理函数代替后续的处理链, 没有指定时直接返回空的成功响应
+func SandboxMirror(sandbox ...HandlerFunc) HandlerFunc {
+	return func(c *Context) {
+		if !c.IsMirror() {
+			return
+		}
+		if len(sandbox) == 0 {
+			c.JSON(nil, nil)
+			c.Abort()
+			return
+		}
+		// 路由的处理链是共享的, 复制一份再替换后续的处理函数
+		handlers := make([]HandlerFunc, 0, int(c.index)+1+len(sandbox))
+		handlers = append(handlers, c.handlers[:c.index+1]...)
+		c.handlers = append(handlers, sandbox...)
+	}
+}
@@ -2,9 +2,20 @@ package pudding
 
 import (
 	"context"
+	"github.com/bdjimmy/pudding/binding"
+	"github.com/bdjimmy/pudding/ecode"
+	"github.com/bdjimmy/pudding/metadata"
 	"github.com/bdjimmy/pudding/render"
+	"github.com/pkg/errors"
+	"io"
 	"math"
+	"mime/multipart"
 	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 )
 
 const (
@@ -35,10 +46,84 @@ type Context struct {
 	// Keys is key/value pair exclusively for the context of each request.
 	Keys map[string]interface{}
 
+	// Error 处理过程中产生的错误, JSON 渲染时会记录业务错误, 供中间件记录日志和统计
 	Error error
 
+	// Params 路由中解析出的参数, 例如 /user/:id 中的 id
+	Params Params
+	// RoutePath 匹配到的路由模板, 例如 /user/:id, 未匹配到路由时为空
+	RoutePath string
+
 	method string
 	engine *Engine
+	// routeColor 匹配到的染色路由的染色标记, 匹配默认路由时为空
+	routeColor string
+
+	// 以下字段随Context复用, 避免每个请求分配内存
+	writermem responseWriter
+	mdCtx     metadata.Lazy
+	// 请求的超时上下文, 不使用context.WithTimeout, 避免每个请求分配内存
+	deadline deadlineCtx
+	// 表单延迟解析, 以及路由的请求体限制
+	formParsed bool
+	formErr    error
+	queryCache url.Values
+	// 请求匹配到的MethodConfig, 可能为空, 以及合并了引擎默认配置之后生效的配置
+	methodConfig    *MethodConfig
+	effectiveConfig MethodConfig
+}
+
+// reset 重置从池中取出的Context
+func (c *Context) reset() {
+	c.Context = nil
+	c.Writer = &c.writermem
+	c.index = -1
+	c.handlers = nil
+	c.Keys = nil
+	c.Error = nil
+	c.Params = c.Params[:0]
+	c.RoutePath = ""
+	c.method = ""
+	c.routeColor = ""
+	c.formParsed = false
+	c.formErr = nil
+	c.queryCache = nil
+	c.methodConfig = nil
+}
+
+// Copy returns a copy of the current context that can be safely used outside the request's scope,
+// e.g. in a goroutine. The context is pooled and reused after the handlers return.
+// The metadata is kept and the deadline is dropped
+// Context会被复用, 在协程中使用时必须调用Copy, 复制的Context保留metadata但没有超时时间
+func (c *Context) Copy() *Context {
+	cp := &Context{
+		Context:   metadata.WithContext(c),
+		Request:   c.Request,
+		index:     _abortIndex,
+		Error:     c.Error,
+		RoutePath: c.RoutePath,
+		method:    c.method,
+		engine:    c.engine,
+
+		routeColor: c.routeColor,
+
+		formParsed: c.formParsed,
+		formErr:    c.formErr,
+		queryCache: c.queryCache,
+
+		methodConfig:    c.methodConfig,
+		effectiveConfig: c.effectiveConfig,
+	}
+	cp.writermem = c.writermem
+	cp.Writer = &cp.writermem
+	if c.Keys != nil {
+		cp.Keys = make(map[string]interface{}, len(c.Keys))
+		for k, v := range c.Keys {
+			cp.Keys[k] = v
+		}
+	}
+	cp.Params = append(Params(nil), c.Params...)
+	return cp
 }
 
 /******************************************/
@@ -50,15 +135,8 @@ type Context struct {
 func (c *Context) Next() {
 	c.index++
 	s := int8(len(c.handlers))
+	// 处理链在分发时已经按照方法和路径选好, 不匹配的请求走NoMethod/NoRoute处理链
 	for ; c.index < s; c.index++ {
-		// only check method on last handler, otherwise middlewares will never be effected if request method is not matched
-		// 检测最后一个注册函数是否匹配method
-		if c.index == s-1 && c.method != c.Request.Method {
-			code := http.StatusMethodNotAllowed
-			//c.Error = ecode.MethodNotAllowed
-			http.Error(c.Writer, http.StatusText(code), code)
-			return
-		}
 		c.handlers[c.index](c)
 	}
 }
@@ -100,6 +178,143 @@ func (c *Context) Get(key string) (value interface{}, exists bool) {
 	return
 }
 
+// Param returns the value of the URL param.
+// It is a shortcut for c.Params.ByName(key)
+//     router.GET("/user/:id", func(c *pudding.Context) {
+//         // a GET request to /user/john
+//         id := c.Param("id") // id == "john"
+//     })
+func (c *Context) Param(key string) string {
+	return c.Params.ByName(key)
+}
+
+// MethodConfig returns the effective config of the request: the config of the matched route,
+// or the longest group prefix, merged with the engine defaults of ServerConfig
+// 返回请求生效的配置: 路由的配置或者最长的group前缀的配置, 未设置的字段使用ServerConfig中的默认值
+func (c *Context) MethodConfig() MethodConfig {
+	return c.effectiveConfig
+}
+
+/******************************************/
+/************* request params *************/
+/******************************************/
+
+// Query returns the first value of the URL query by key, the query is parsed once per request
+func (c *Context) Query(key string) string {
+	if c.queryCache == nil {
+		c.queryCache = c.Request.URL.Query()
+	}
+	return c.queryCache.Get(key)
+}
+
+// PostForm returns the first value of the urlencoded or multipart body form by key
+func (c *Context) PostForm(key string) string {
+	c.ParseForm()
+	return c.Request.PostForm.Get(key)
+}
+
+// FormValue returns the first value for the named key from the query or the body form
+func (c *Context) FormValue(key string) string {
+	c.ParseForm()
+	return c.Request.Form.Get(key)
+}
+
+// FormFile returns the first uploaded file for the form key,
+// the error of parsing the form is returned, e.g. the body is too large
+func (c *Context) FormFile(name string) (*multipart.FileHeader, error) {
+	if err := c.ParseForm(); err != nil {
+		return nil, err
+	}
+	if mf := c.Request.MultipartForm; mf != nil {
+		if fhs := mf.File[name]; len(fhs) > 0 {
+			return fhs[0], nil
+		}
+	}
+	return nil, http.ErrMissingFile
+}
+
+// SaveUploadedFile saves the uploaded file to dst, the parent directories are created if needed
+func (c *Context) SaveUploadedFile(file *multipart.FileHeader, dst string) error {
+	src, err := file.Open()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer src.Close()
+	if err = os.MkdirAll(filepath.Dir(dst), 0750); err != nil {
+		return errors.WithStack(err)
+	}
+	out, err := os.Create(dst)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer out.Close()
+	_, err = io.Copy(out, src)
+	return errors.WithStack(err)
+}
+
+// ParseForm parses the query and the body form of the request, multipart form included,
+// it's called lazily when the handlers read the params, the result is cached.
+// The body limits of the engine and the route are applied, the error is
+// ecode.RequestTooLarge if a limit is exceeded, otherwise ecode.RequestErr
+// 表单在第一次读取参数时才解析, 之后直接返回解析的结果
+func (c *Context) ParseForm() error {
+	if c.formParsed {
+		return c.formErr
+	}
+	c.formParsed = true
+	c.formErr = c.parseForm()
+	return c.formErr
+}
+
+func (c *Context) parseForm() error {
+	req := c.Request
+	if !strings.Contains(req.Header.Get("Content-Type"), binding.MIMEMultipartPOSTForm) {
+		if err := req.ParseForm(); err != nil {
+			return bodyError(err)
+		}
+		return nil
+	}
+	mc := &c.effectiveConfig
+	maxMemory := mc.MaxMultipartMemory
+	if maxMemory <= 0 {
+		maxMemory = defaultMaxMemory
+	}
+	if err := req.ParseMultipartForm(maxMemory); err != nil && err != http.ErrNotMultipart {
+		return bodyError(err)
+	}
+	if mc.MaxFiles > 0 && req.MultipartForm != nil {
+		files := 0
+		for _, fhs := range req.MultipartForm.File {
+			files += len(fhs)
+		}
+		if files > mc.MaxFiles {
+			return ecode.Wrap(ecode.RequestTooLarge, errors.Errorf("too many files: %d > %d", files, mc.MaxFiles))
+		}
+	}
+	return nil
+}
+
+// bodyError 请求体超过限制时返回RequestTooLarge, 其他错误返回RequestErr
+func bodyError(err error) error {
+	var mbe *http.MaxBytesError
+	if errors.As(err, &mbe) {
+		return ecode.Wrap(ecode.RequestTooLarge, err)
+	}
+	return ecode.Wrap(ecode.RequestErr, errors.WithStack(err))
+}
+
+// AbortWithBodyError renders the error returned by ParseForm, FormFile etc. and aborts the chain,
+// the http status is 413 for ecode.RequestTooLarge and 400 otherwise
+// 渲染请求体的错误并中断处理链, http状态码为400或者413
+func (c *Context) AbortWithBodyError(err error) {
+	status := http.StatusBadRequest
+	if ecode.EqualError(ecode.RequestTooLarge, err) {
+		status = http.StatusRequestEntityTooLarge
+	}
+	c.renderJSON(status, nil, err)
+	c.Abort()
+}
+
 /******************************************/
 /***********  response rending  **********/
 /******************************************/
@@ -150,26 +365,99 @@ func (c *Context) Bytes(code int, contentType string, data ...[]byte) {
 	})
 }
 
-func (c *Context) JSON(errno int, message string, data interface{}) {
-	// http code
-	code := http.StatusOK
-	//c.Error = err
+// JSON serializes the given data and error as the standard JSON envelope into the response body.
+// The code and message are taken from err by ecode.Cause, and err is stored in c.Error
+// 业务错误码和提示信息从err中获取, 并记录到c.Error中供中间件使用
+func (c *Context) JSON(data interface{}, err error) {
+	c.renderJSON(http.StatusOK, data, err)
+}
+
+// renderJSON 以指定的http状态码渲染JSON
+func (c *Context) renderJSON(code int, data interface{}, err error) {
+	c.Error = err
+	bcode := ecode.Cause(err)
+	writeStatusCode(c.Writer, bcode.Code())
 	c.Render(code, render.JSON{
-		Code:    errno,
-		Message: message,
+		Code:    bcode.Code(),
+		Message: bcode.Message(),
 		Data:    data,
 	})
-
 }
 
-func (c *Context) JSONMap(errno int, message string, data map[string]interface{}) {
+// JSONMap serializes the given map and error into the response body.
+// The code and message are set into the map
+func (c *Context) JSONMap(data map[string]interface{}, err error) {
 	// http code
 	code := http.StatusOK
-	//c.Error = err
-	data["code"] = errno
-	data["message"] = message
+	c.Error = err
+	bcode := ecode.Cause(err)
+	writeStatusCode(c.Writer, bcode.Code())
+	if data == nil {
+		data = make(map[string]interface{})
+	}
+	data["code"] = bcode.Code()
+	data["message"] = bcode.Message()
 	c.Render(code, render.MapJSON(data))
+}
+
+/******************************************/
+/************** input binding *************/
+/******************************************/
+
+// Bind checks the Content-Type to select a binding engine automatically,
+// Depending the "Content-Type" header different bindings are used:
+//     "application/json" --> JSON binding
+//     "application/xml"  --> XML binding
+//     "multipart/form-data" --> Multipart form binding, including files
+//     otherwise --> Form binding, include query and body form
+// It decodes the request into obj and validates it by the validate tags.
+// If the binding failed, a request error JSON is rendered and the handler chain is aborted
+func (c *Context) Bind(obj interface{}) error {
+	b := binding.Default(c.Request.Method, c.Request.Header.Get("Content-Type"))
+	return c.mustBindWith(obj, b)
+}
+
+// BindWith bind req arg with parser.
+func (c *Context) BindWith(obj interface{}, b binding.Binding) error {
+	return c.mustBindWith(obj, b)
+}
+
+func (c *Context) mustBindWith(obj interface{}, b binding.Binding) (err error) {
+	// 表单按照引擎和路由的限制解析, 请求体错误返回400或者413
+	mb, multipart := b.(binding.MultipartBinding)
+	if multipart || b == binding.FormPost {
+		if err = c.ParseForm(); err != nil {
+			c.AbortWithBodyError(err)
+			return
+		}
+	}
+	if multipart {
+		err = mb.BindMaxMemory(c.Request, obj, c.effectiveConfig.MaxMultipartMemory)
+	} else {
+		err = b.Bind(c.Request, obj)
+	}
+	if err != nil {
+		var mbe *http.MaxBytesError
+		if errors.As(err, &mbe) {
+			err = bodyError(err)
+			c.AbortWithBodyError(err)
+			return
+		}
+		// 校验失败时返回每个字段的错误
+		var data interface{}
+		if ve, ok := errors.Cause(err).(binding.ValidationErrors); ok {
+			data = ve
+		}
+		c.JSON(data, ecode.Error(ecode.RequestErr, err.Error()))
+		c.Abort()
+	}
+	return
+}
 
+// writeStatusCode 在响应头中记录业务码, 供监控和日志使用
+func writeStatusCode(w http.ResponseWriter, ecode int) {
+	header := w.Header()
+	header.Set(_httpHeaderStatusCode, strconv.FormatInt(int64(ecode), 10))
 }
 
 // 根据状态码判断是否允许设置body
@@ -0,0 +1,276 @@
+package pudding
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bdjimmy/pudding/ecode"
+	"github.com/bdjimmy/pudding/utils"
+	"github.com/pkg/errors"
+)
+
+func TestServeHTTPZeroAllocs(t *testing.T) {
+	engine := New()
+	engine.GET("/ping", func(c *Context) {})
+	engine.GET("/user/:id", func(c *Context) { _ = c.Param("id") })
+	for _, path := range []string{"/ping", "/user/42", "/missing"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		w := &benchWriter{header: make(http.Header)}
+		// 预热序列和池
+		engine.ServeHTTP(w, req)
+		if n := testing.AllocsPerRun(100, func() { engine.ServeHTTP(w, req) }); n != 0 {
+			t.Errorf("%s: %v allocs per request", path, n)
+		}
+	}
+}
+
+func TestContextPoolReset(t *testing.T) {
+	engine := New()
+	var keys map[string]interface{}
+	var route string
+	var params Params
+	engine.GET("/a/:id", func(c *Context) {
+		c.Set("k", "v")
+		c.Error = context.Canceled
+	})
+	engine.GET("/b", func(c *Context) {
+		keys, route, params = c.Keys, c.RoutePath, c.Params
+		if c.Error != nil || c.Request.Form != nil {
+			t.Errorf("error(%v) form(%v) leaked", c.Error, c.Request.Form)
+		}
+	})
+	serve := func(path string) {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		engine.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	serve("/a/1")
+	serve("/b?x=1")
+	if keys != nil || route != "/b" || len(params) != 0 {
+		t.Fatalf("keys(%v) route(%s) params(%v)", keys, route, params)
+	}
+}
+
+func TestContextCopy(t *testing.T) {
+	engine := New()
+	var cp *Context
+	var ctx context.Context
+	engine.GET("/user/:id", func(c *Context) {
+		c.Set("k", "v")
+		ctx, cp = c.Context, c.Copy()
+	})
+	engine.GET("/other/:name", func(c *Context) {})
+	req := httptest.NewRequest(http.MethodGet, "/user/42", nil)
+	req.Header.Set(_httpHeaderColor, "canary")
+	req.Header.Set(_httpHeaderTimeout, "1000000")
+	engine.ServeHTTP(httptest.NewRecorder(), req)
+	// 请求结束后取消, 复用Context处理其他请求不影响Copy
+	if ctx.Err() != context.Canceled {
+		t.Fatalf("request context: %v", ctx.Err())
+	}
+	engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/other/x", nil))
+	if cp.RoutePath != "/user/:id" || cp.Param("id") != "42" || cp.Keys["k"] != "v" || cp.Color() != "canary" {
+		t.Fatalf("copy: route(%s) id(%s) keys(%v) color(%s)", cp.RoutePath, cp.Param("id"), cp.Keys, cp.Color())
+	}
+	if _, ok := cp.Deadline(); ok || cp.Err() != nil {
+		t.Fatal("copy keeps the deadline of the request")
+	}
+}
+
+func TestRequestDeadline(t *testing.T) {
+	engine := New()
+	var err error
+	var deadline bool
+	engine.GET("/slow", func(c *Context) {
+		_, deadline = c.Deadline()
+		select {
+		case <-c.Done():
+			err = c.Err()
+		case <-time.After(time.Second):
+		}
+	})
+	req := httptest.NewRequest(http.MethodGet, "/slow", nil)
+	// 10ms, 单位是微秒
+	req.Header.Set(_httpHeaderTimeout, "10000")
+	engine.ServeHTTP(httptest.NewRecorder(), req)
+	if !deadline || err != context.DeadlineExceeded {
+		t.Fatalf("deadline(%v) err(%v)", deadline, err)
+	}
+}
+
+func TestDeadlineCtxReuse(t *testing.T) {
+	d := &deadlineCtx{}
+	d.reset(context.Background(), 10*time.Millisecond)
+	done := d.Done()
+	d.cancel(context.Canceled)
+	select {
+	case <-done:
+	default:
+		t.Fatal("done not closed by cancel")
+	}
+	// 上一个请求的定时器不能取消复用后的请求
+	d.reset(context.Background(), 10*time.Millisecond)
+	d.Done()
+	d.reset(context.Background(), 0)
+	d.Done()
+	time.Sleep(30 * time.Millisecond)
+	if err := d.Err(); err != nil {
+		t.Fatalf("reused context canceled by a stale timer: %v", err)
+	}
+	if _, ok := d.Deadline(); ok {
+		t.Fatal("deadline without timeout")
+	}
+	d.reset(context.Background(), time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if err := d.Err(); err != context.DeadlineExceeded {
+		t.Fatalf("err without Done: %v", err)
+	}
+	select {
+	case <-d.Done():
+	default:
+		t.Fatal("done not closed after the deadline")
+	}
+}
+
+func TestContextJSONError(t *testing.T) {
+	cases := []struct {
+		name    string
+		data    interface{}
+		err     error
+		code    int
+		message string
+		hasData bool
+	}{
+		{"ok", map[string]int{"id": 1}, nil, 0, "OK", true},
+		{"code", nil, ecode.NothingFound, -404, "Nothing Found", false},
+		{"status", nil, ecode.Error(ecode.RequestErr, "bad name"), -400, "bad name", false},
+		{"wrap", nil, ecode.Wrap(ecode.LimitExceed, errors.New("quota")), -509, "Limit Exceeded", false},
+		{"pkg wrapped", nil, errors.Wrap(ecode.AccessDenied, "admin"), -403, "Access Denied", false},
+		{"deadline", nil, errors.Wrap(context.DeadlineExceeded, "db"), -504, "Deadline Exceeded", false},
+		{"plain", nil, errors.New("boom"), -500, "Server Error", false},
+	}
+	for _, cs := range cases {
+		engine := New()
+		var cerr error
+		engine.GET("/json", func(c *Context) {
+			c.JSON(cs.data, cs.err)
+			cerr = c.Error
+		})
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/json", nil))
+		var body struct {
+			Code    int             `json:"code"`
+			Message string          `json:"message"`
+			Data    json.RawMessage `json:"data"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("%s: %v %s", cs.name, err, w.Body.String())
+		}
+		// 业务错误使用http 200, 业务码在响应体和响应头中
+		if w.Code != http.StatusOK || body.Code != cs.code || body.Message != cs.message || (len(body.Data) > 0) != cs.hasData {
+			t.Errorf("%s: %d %s", cs.name, w.Code, w.Body.String())
+		}
+		if got := w.Header().Get(_httpHeaderStatusCode); got != strconv.Itoa(cs.code) {
+			t.Errorf("%s: status code header %s", cs.name, got)
+		}
+		if cerr != cs.err {
+			t.Errorf("%s: c.Error %v", cs.name, cerr)
+		}
+	}
+}
+
+// multipartBody 构造包含files个文件的multipart表单
+func multipartBody(t *testing.T, files int) (string, *bytes.Buffer) {
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+	if err := mw.WriteField("a", "1"); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < files; i++ {
+		fw, err := mw.CreateFormFile("file", fmt.Sprintf("f%d.txt", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		fw.Write([]byte("content"))
+	}
+	mw.Close()
+	return mw.FormDataContentType(), body
+}
+
+func TestContextBodyLimits(t *testing.T) {
+	engine := NewServer(&ServerConfig{TimeOut: utils.Duration(time.Second), MaxBodyBytes: 16, MaxFiles: 2})
+	handler := func(c *Context) {
+		if err := c.ParseForm(); err != nil {
+			c.AbortWithBodyError(err)
+			return
+		}
+		c.String(http.StatusOK, c.PostForm("a"))
+	}
+	for _, path := range []string{"/engine", "/large", "/small", "/files"} {
+		engine.POST(path, handler)
+	}
+	// 路由的配置优先, 未设置的字段使用engine的配置
+	engine.SetMethodConfig("/large", &MethodConfig{MaxBodyBytes: 1 << 20, MaxFiles: 3})
+	engine.SetMethodConfig("/small", &MethodConfig{MaxBodyBytes: 4})
+	engine.SetMethodConfig("/files", &MethodConfig{MaxBodyBytes: 1 << 20})
+
+	const form = "application/x-www-form-urlencoded"
+	large := "a=1&b=" + strings.Repeat("x", 32)
+	type bodyCase struct {
+		name, path, contentType string
+		body                    *bytes.Buffer
+		status, code            int
+	}
+	cases := []bodyCase{
+		{"engine ok", "/engine", form, bytes.NewBufferString("a=1"), http.StatusOK, 0},
+		{"engine too large", "/engine", form, bytes.NewBufferString(large), http.StatusRequestEntityTooLarge, -413},
+		{"route larger than engine", "/large", form, bytes.NewBufferString(large), http.StatusOK, 0},
+		{"route smaller than engine", "/small", form, bytes.NewBufferString("a=12345"), http.StatusRequestEntityTooLarge, -413},
+		{"malformed form", "/engine", form, bytes.NewBufferString("a=%zz"), http.StatusBadRequest, -400},
+		{"malformed multipart", "/large", "multipart/form-data; boundary=xxx", bytes.NewBufferString("garbage"), http.StatusBadRequest, -400},
+		{"multipart without boundary", "/large", "multipart/form-data", bytes.NewBufferString("garbage"), http.StatusBadRequest, -400},
+	}
+	ct, body := multipartBody(t, 1)
+	cases = append(cases, bodyCase{"multipart too large", "/engine", ct, body, http.StatusRequestEntityTooLarge, -413})
+	for _, c := range []struct {
+		path   string
+		files  int
+		status int
+		code   int
+	}{
+		{"/files", 2, http.StatusOK, 0},
+		// MaxFiles 使用engine的配置
+		{"/files", 3, http.StatusRequestEntityTooLarge, -413},
+		{"/large", 3, http.StatusOK, 0},
+		{"/large", 4, http.StatusRequestEntityTooLarge, -413},
+	} {
+		ct, body := multipartBody(t, c.files)
+		cases = append(cases, bodyCase{fmt.Sprintf("%d files", c.files), c.path, ct, body, c.status, c.code})
+	}
+
+	for _, cs := range cases {
+		req := httptest.NewRequest(http.MethodPost, cs.path, cs.body)
+		req.Header.Set("Content-Type", cs.contentType)
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		if w.Code != cs.status {
+			t.Errorf("%s %s: %d %s", cs.name, cs.path, w.Code, w.Body.String())
+			continue
+		}
+		if cs.code == 0 {
+			if w.Body.String() != "1" {
+				t.Errorf("%s %s: %s", cs.name, cs.path, w.Body.String())
+			}
+		} else if !strings.Contains(w.Body.String(), `"code":`+strconv.Itoa(cs.code)) {
+			t.Errorf("%s %s: %s", cs.name, cs.path, w.Body.String())
+		}
+	}
+}
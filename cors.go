@@ -0,0 +1,262 @@
+package pudding
+
+import (
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	_httpHeaderOrigin           = "Origin"
+	_httpHeaderVary             = "Vary"
+	_httpHeaderRequestMethod    = "Access-Control-Request-Method"
+	_httpHeaderRequestHeaders   = "Access-Control-Request-Headers"
+	_httpHeaderAllowOrigin      = "Access-Control-Allow-Origin"
+	_httpHeaderAllowMethods     = "Access-Control-Allow-Methods"
+	_httpHeaderAllowHeaders     = "Access-Control-Allow-Headers"
+	_httpHeaderAllowCredentials = "Access-Control-Allow-Credentials"
+	_httpHeaderExposeHeaders    = "Access-Control-Expose-Headers"
+	_httpHeaderMaxAge           = "Access-Control-Max-Age"
+	_corsWildcard               = "*"
+)
+
+var (
+	_corsDefaultMethods = []string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodDelete}
+	_corsDefaultHeaders = []string{"Origin", "Accept", "Content-Type", "X-Requested-With"}
+)
+
+// CORSConfig is the CORS policy, an origin is allowed if any of AllowOrigins, AllowOriginPatterns
+// and AllowOriginFunc allows it
+type CORSConfig struct {
+	// AllowOrigins 允许的来源, 精确匹配或者带一个*的通配符, 例如 https://*.example.com, * 允许所有来源
+	AllowOrigins []string
+	// AllowOriginPatterns 允许的来源的正则表达式, 例如 ^https://[a-z]+\.example\.com$
+	AllowOriginPatterns []string
+	// AllowOriginFunc 自定义的来源校验
+	AllowOriginFunc func(origin string) bool
+	// AllowMethods 允许的方法, 默认为 GET, HEAD, POST, PUT, DELETE
+	AllowMethods []string
+	// AllowHeaders 允许的请求头, 默认为 Origin, Accept, Content-Type, X-Requested-With, * 允许所有请求头
+	AllowHeaders []string
+	// AllowCredentials 是否允许携带cookie等凭证, 此时不会返回 Access-Control-Allow-Origin: *
+	AllowCredentials bool
+	// ExposeHeaders 允许浏览器读取的响应头
+	ExposeHeaders []string
+	// MaxAge 预检请求结果的缓存时间, 0 不设置
+	MaxAge time.Duration
+}
+
+// cors 编译后的CORS策略
+type cors struct {
+	conf      CORSConfig
+	allowAll  bool
+	origins   map[string]struct{}
+	wildcards [][2]string
+	patterns  []*regexp.Regexp
+	methods   map[string]struct{}
+	// headers 小写的请求头, anyHeader 为true时允许所有请求头
+	headers   map[string]struct{}
+	anyHeader bool
+
+	allowMethods  string
+	allowHeaders  string
+	exposeHeaders string
+	maxAge        string
+}
+
+// CORS returns the middleware applying the CORS policy, e.g. group.UseFunc(CORS(conf)).
+// The preflight requests are answered with 204 and abort the chain, the disallowed preflight
+// requests get 403. The preflight requests of the routes without OPTIONS handlers only run
+// the CORS middleware of the route, the other middleware, e.g. the authentication, is skipped.
+// The group middleware runs after the MethodConfig checks, register it by engine.UseFunc
+// if the errors of the quota and auth checks need the CORS headers.
+// The responses of the disallowed origins have no CORS headers and are blocked by the browser
+// 按group配置的CORS中间件, 预检请求直接应答并中断处理链, 自动应答的预检请求只执行CORS中间件
+func CORS(conf *CORSConfig) HandlerFunc {
+	p := newCORS(conf)
+	return p.handle
+}
+
+// _corsHandler CORS中间件的函数指针, 所有cors实例的handle方法值共用同一个函数
+var _corsHandler = reflect.ValueOf((&cors{}).handle).Pointer()
+
+// isCORS reports whether h is the middleware returned by CORS
+func isCORS(h HandlerFunc) bool {
+	return reflect.ValueOf(h).Pointer() == _corsHandler
+}
+
+func newCORS(conf *CORSConfig) *cors {
+	p := &cors{
+		origins: make(map[string]struct{}),
+		methods: make(map[string]struct{}),
+		headers: make(map[string]struct{}),
+	}
+	if conf != nil {
+		p.conf = *conf
+	}
+	for _, origin := range p.conf.AllowOrigins {
+		origin = strings.ToLower(origin)
+		switch i := strings.Index(origin, _corsWildcard); {
+		case origin == _corsWildcard:
+			p.allowAll = true
+		case i >= 0:
+			p.wildcards = append(p.wildcards, [2]string{origin[:i], origin[i+1:]})
+		default:
+			p.origins[origin] = struct{}{}
+		}
+	}
+	for _, pattern := range p.conf.AllowOriginPatterns {
+		p.patterns = append(p.patterns, regexp.MustCompile(pattern))
+	}
+	methods := p.conf.AllowMethods
+	if len(methods) == 0 {
+		methods = _corsDefaultMethods
+	}
+	upper := make([]string, 0, len(methods))
+	for _, m := range methods {
+		m = strings.ToUpper(m)
+		p.methods[m] = struct{}{}
+		upper = append(upper, m)
+	}
+	p.allowMethods = strings.Join(upper, ", ")
+	headers := p.conf.AllowHeaders
+	if len(headers) == 0 {
+		headers = _corsDefaultHeaders
+	}
+	for _, h := range headers {
+		if h == _corsWildcard {
+			p.anyHeader = true
+			continue
+		}
+		p.headers[strings.ToLower(h)] = struct{}{}
+	}
+	p.allowHeaders = strings.Join(headers, ", ")
+	p.exposeHeaders = strings.Join(p.conf.ExposeHeaders, ", ")
+	if p.conf.MaxAge > 0 {
+		p.maxAge = strconv.FormatInt(int64(p.conf.MaxAge/time.Second), 10)
+	}
+	return p
+}
+
+func (p *cors) handle(c *Context) {
+	header := c.Writer.Header()
+	origin := c.Request.Header.Get(_httpHeaderOrigin)
+	preflight := c.Request.Method == http.MethodOptions && c.Request.Header.Get(_httpHeaderRequestMethod) != ""
+	// 响应随Origin变化, 需要告诉缓存按请求头区分
+	if !p.allowAll || p.conf.AllowCredentials {
+		addVary(header, _httpHeaderOrigin)
+	}
+	if preflight {
+		addVary(header, _httpHeaderRequestMethod)
+		addVary(header, _httpHeaderRequestHeaders)
+	}
+	if origin == "" {
+		return
+	}
+	if !p.allowOrigin(origin) {
+		if preflight {
+			c.AbortWithStatus(http.StatusForbidden)
+		}
+		return
+	}
+	if preflight {
+		p.handlePreflight(c, origin)
+		return
+	}
+	p.setOrigin(header, origin)
+	if p.exposeHeaders != "" {
+		header.Set(_httpHeaderExposeHeaders, p.exposeHeaders)
+	}
+}
+
+// handlePreflight 校验预检请求的方法和请求头, 并直接应答
+func (p *cors) handlePreflight(c *Context, origin string) {
+	header := c.Writer.Header()
+	method := strings.ToUpper(c.Request.Header.Get(_httpHeaderRequestMethod))
+	if _, ok := p.methods[method]; !ok {
+		c.AbortWithStatus(http.StatusForbidden)
+		return
+	}
+	reqHeaders := c.Request.Header.Get(_httpHeaderRequestHeaders)
+	if !p.allowRequestHeaders(reqHeaders) {
+		c.AbortWithStatus(http.StatusForbidden)
+		return
+	}
+	p.setOrigin(header, origin)
+	header.Set(_httpHeaderAllowMethods, p.allowMethods)
+	if p.anyHeader {
+		// 允许所有请求头时回显请求的请求头, 携带凭证时浏览器不接受 *
+		if reqHeaders != "" {
+			header.Set(_httpHeaderAllowHeaders, reqHeaders)
+		}
+	} else if p.allowHeaders != "" {
+		header.Set(_httpHeaderAllowHeaders, p.allowHeaders)
+	}
+	if p.maxAge != "" {
+		header.Set(_httpHeaderMaxAge, p.maxAge)
+	}
+	c.AbortWithStatus(http.StatusNoContent)
+}
+
+func (p *cors) setOrigin(header http.Header, origin string) {
+	if p.allowAll && !p.conf.AllowCredentials {
+		header.Set(_httpHeaderAllowOrigin, _corsWildcard)
+		return
+	}
+	header.Set(_httpHeaderAllowOrigin, origin)
+	if p.conf.AllowCredentials {
+		header.Set(_httpHeaderAllowCredentials, "true")
+	}
+}
+
+func (p *cors) allowOrigin(origin string) bool {
+	if p.allowAll {
+		return true
+	}
+	lower := strings.ToLower(origin)
+	if _, ok := p.origins[lower]; ok {
+		return true
+	}
+	for _, w := range p.wildcards {
+		if len(lower) >= len(w[0])+len(w[1]) && strings.HasPrefix(lower, w[0]) && strings.HasSuffix(lower, w[1]) {
+			return true
+		}
+	}
+	for _, re := range p.patterns {
+		if re.MatchString(origin) {
+			return true
+		}
+	}
+	return p.conf.AllowOriginFunc != nil && p.conf.AllowOriginFunc(origin)
+}
+
+// allowRequestHeaders 校验 Access-Control-Request-Headers 中的请求头
+func (p *cors) allowRequestHeaders(reqHeaders string) bool {
+	if p.anyHeader || reqHeaders == "" {
+		return true
+	}
+	for _, h := range strings.Split(reqHeaders, ",") {
+		if h = strings.ToLower(strings.TrimSpace(h)); h == "" {
+			continue
+		}
+		if _, ok := p.headers[h]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// addVary 添加Vary头, 已经存在时不重复添加
+func addVary(header http.Header, value string) {
+	for _, v := range header.Values(_httpHeaderVary) {
+		for _, s := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(s), value) {
+				return
+			}
+		}
+	}
+	header.Add(_httpHeaderVary, value)
+}
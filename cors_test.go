@@ -0,0 +1,41 @@
+package pudding
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCORSPreflightSkipsMiddleware(t *testing.T) {
+	engine := New()
+	// 注册在CORS之前的鉴权中间件拒绝没有凭证的请求
+	deny := func(c *Context) { c.AbortWithStatus(http.StatusUnauthorized) }
+	api := engine.Group("/api", deny, CORS(&CORSConfig{AllowOrigins: []string{"https://example.com"}}))
+	api.PUT("/user", func(c *Context) { c.String(http.StatusOK, "ok") })
+
+	req := httptest.NewRequest(http.MethodOptions, "/api/user", nil)
+	req.Header.Set(_httpHeaderOrigin, "https://example.com")
+	req.Header.Set(_httpHeaderRequestMethod, http.MethodPut)
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	if w.Code != http.StatusNoContent || w.Header().Get(_httpHeaderAllowOrigin) != "https://example.com" {
+		t.Fatalf("preflight: %d %v", w.Code, w.Header())
+	}
+
+	req = httptest.NewRequest(http.MethodPut, "/api/user", nil)
+	req.Header.Set(_httpHeaderOrigin, "https://example.com")
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("request: %d", w.Code)
+	}
+}
+
+func TestIsCORS(t *testing.T) {
+	if !isCORS(CORS(nil)) || !isCORS(CORS(&CORSConfig{AllowOrigins: []string{"*"}})) {
+		t.Fatal("CORS middleware not detected")
+	}
+	if isCORS(func(c *Context) {}) || isCORS(defaultOptions) {
+		t.Fatal("other handler detected as CORS")
+	}
+}
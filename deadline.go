@@ -0,0 +1,111 @@
+package pudding
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// deadlineCtx is the cancelable request context embedded in the pooled Context.
+// The done channel and the timer are created on the first Done call, so the requests
+// never waiting on Done don't allocate for the timeout.
+// 请求的超时上下文, 随Context复用, 第一次调用Done时才创建channel和定时器,
+// 父context不能被取消, 服务端使用的是基于Background的metadata上下文
+type deadlineCtx struct {
+	parent   context.Context
+	deadline time.Time
+
+	mu    sync.Mutex
+	done  chan struct{}
+	timer *time.Timer
+	err   error
+	// gen 每次复用时递增, 上一个请求的定时器触发时不会取消当前请求
+	gen uint64
+}
+
+var _ context.Context = &deadlineCtx{}
+
+// reset 复用前重置, timeout为0时没有超时时间
+func (d *deadlineCtx) reset(parent context.Context, timeout time.Duration) {
+	d.mu.Lock()
+	d.parent = parent
+	d.deadline = time.Time{}
+	if timeout > 0 {
+		d.deadline = time.Now().Add(timeout)
+	}
+	d.done = nil
+	d.timer = nil
+	d.err = nil
+	d.gen++
+	d.mu.Unlock()
+}
+
+// Deadline implements context.Context
+func (d *deadlineCtx) Deadline() (time.Time, bool) {
+	return d.deadline, !d.deadline.IsZero()
+}
+
+// Done implements context.Context, the channel is closed when the deadline is exceeded
+// or the request finished
+func (d *deadlineCtx) Done() <-chan struct{} {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.done != nil {
+		return d.done
+	}
+	d.done = make(chan struct{})
+	switch {
+	case d.err != nil:
+		close(d.done)
+	case !d.deadline.IsZero():
+		dur := time.Until(d.deadline)
+		if dur <= 0 {
+			d.cancelLocked(context.DeadlineExceeded)
+			break
+		}
+		gen := d.gen
+		d.timer = time.AfterFunc(dur, func() {
+			d.mu.Lock()
+			if d.gen == gen {
+				d.cancelLocked(context.DeadlineExceeded)
+			}
+			d.mu.Unlock()
+		})
+	}
+	return d.done
+}
+
+// Err implements context.Context
+func (d *deadlineCtx) Err() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.err == nil && !d.deadline.IsZero() && !time.Now().Before(d.deadline) {
+		d.cancelLocked(context.DeadlineExceeded)
+	}
+	return d.err
+}
+
+// Value implements context.Context
+func (d *deadlineCtx) Value(key interface{}) interface{} {
+	return d.parent.Value(key)
+}
+
+// cancel 请求处理完之后取消, 已经取消时不修改错误
+func (d *deadlineCtx) cancel(err error) {
+	d.mu.Lock()
+	d.cancelLocked(err)
+	d.mu.Unlock()
+}
+
+func (d *deadlineCtx) cancelLocked(err error) {
+	if d.err != nil {
+		return
+	}
+	d.err = err
+	if d.done != nil {
+		close(d.done)
+	}
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+}
@@ -0,0 +1,57 @@
+package ecode
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// All common ecode
+var (
+	OK = add(0) // 正确
+
+	NotModified        = add(-304) // 木有改动
+	TemporaryRedirect  = add(-307) // 撞车跳转
+	RequestErr         = add(-400) // 请求错误
+	Unauthorized       = add(-401) // 未认证
+	AccessDenied       = add(-403) // 访问权限不足
+	NothingFound       = add(-404) // 啥都木有
+	MethodNotAllowed   = add(-405) // 不支持该方法
+	Conflict           = add(-409) // 冲突
+	RequestTooLarge    = add(-413) // 请求体过大
+	Canceled           = add(-498) // 客户端取消请求
+	ServerErr          = add(-500) // 服务器错误
+	ServiceUnavailable = add(-503) // 过载保护,服务暂不可用
+	Deadline           = add(-504) // 服务调用超时
+	LimitExceed        = add(-509) // 超出限制
+)
+
+// 公共错误码的默认提示信息, 可以通过Register覆盖
+var _defaultMessages = map[int]string{
+	0:    "OK",
+	-304: "Not Modified",
+	-307: "Temporary Redirect",
+	-400: "Request Error",
+	-401: "Unauthorized",
+	-403: "Access Denied",
+	-404: "Nothing Found",
+	-405: "Method Not Allowed",
+	-409: "Conflict",
+	-413: "Request Entity Too Large",
+	-498: "Canceled",
+	-500: "Server Error",
+	-503: "Service Unavailable",
+	-504: "Deadline Exceeded",
+	-509: "Limit Exceeded",
+}
+
+// mapError 把非ecode的错误转换成公共错误码, errors.Wrap 和 fmt.Errorf("%w") 包装的错误同样识别
+func mapError(err error) Codes {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return Canceled
+	case errors.Is(err, context.DeadlineExceeded):
+		return Deadline
+	}
+	return ServerErr
+}
@@ -0,0 +1,129 @@
+// Package ecode is the business error code of pudding.
+// Negative codes are reserved by pudding, business codes should be positive.
+package ecode
+
+import (
+	"fmt"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// 已经注册的错误码, 防止重复定义
+	_codes = map[int]struct{}{}
+	// 错误码对应的提示信息, map[int]string
+	_messages atomic.Value
+)
+
+// Codes ecode error interface which has a code & message.
+type Codes interface {
+	// sometimes Error return Code in string form
+	// NOTE: don't use Error in monitor report even it also work for now
+	Error() string
+	// Code get error code.
+	Code() int
+	// Message get code message.
+	Message() string
+	// Details get error detail,it may be nil.
+	Details() []interface{}
+}
+
+// A Code is an int error code spec.
+type Code int
+
+var _ Codes = Code(0)
+
+// New new a ecode.Codes by int value.
+// NOTE: ecode must unique in global, the New will check repeat and then panic.
+func New(e int) Code {
+	if e <= 0 {
+		panic("business ecode must greater than zero")
+	}
+	return add(e)
+}
+
+func add(e int) Code {
+	if _, ok := _codes[e]; ok {
+		panic(fmt.Sprintf("ecode: %d already exist", e))
+	}
+	_codes[e] = struct{}{}
+	return Int(e)
+}
+
+// Register register ecode message map, it replaces the registered messages.
+func Register(cm map[int]string) {
+	_messages.Store(cm)
+}
+
+// Int parse code int to error.
+func Int(i int) Code { return Code(i) }
+
+// String parse code string to error.
+func String(e string) Code {
+	if e == "" {
+		return OK
+	}
+	// try error string
+	i, err := strconv.Atoi(e)
+	if err != nil {
+		return ServerErr
+	}
+	return Code(i)
+}
+
+func (e Code) Error() string {
+	return strconv.FormatInt(int64(e), 10)
+}
+
+// Code return error code
+func (e Code) Code() int { return int(e) }
+
+// Message return error message, registered messages first, then the default ones
+func (e Code) Message() string {
+	if cm, ok := _messages.Load().(map[int]string); ok {
+		if msg, ok := cm[e.Code()]; ok {
+			return msg
+		}
+	}
+	if msg, ok := _defaultMessages[e.Code()]; ok {
+		return msg
+	}
+	return e.Error()
+}
+
+// Details return details.
+func (e Code) Details() []interface{} { return nil }
+
+// Cause maps an arbitrary error to Codes.
+// 1. nil is OK
+// 2. the Codes in the error chain, the outermost one first
+// 3. context canceled and deadline exceeded are Canceled and Deadline
+// 4. otherwise ServerErr
+func Cause(err error) Codes {
+	if err == nil {
+		return OK
+	}
+	var ec Codes
+	if errors.As(err, &ec) {
+		return ec
+	}
+	return mapError(err)
+}
+
+// Equal equal a and b by code int.
+func Equal(a, b Codes) bool {
+	if a == nil {
+		a = OK
+	}
+	if b == nil {
+		b = OK
+	}
+	return a.Code() == b.Code()
+}
+
+// EqualError equal error
+func EqualError(code Codes, err error) bool {
+	return Equal(code, Cause(err))
+}
@@ -0,0 +1,114 @@
+package ecode
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+var _testCode = New(10001)
+
+func TestCause(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		code int
+	}{
+		{"nil", nil, 0},
+		{"code", AccessDenied, -403},
+		{"status", Error(RequestErr, "bad"), -400},
+		{"pkg wrapped code", errors.Wrap(NothingFound, "user"), -404},
+		{"fmt wrapped code", fmt.Errorf("user: %w", _testCode), 10001},
+		{"wrap", Wrap(LimitExceed, errors.New("quota")), -509},
+		// 最外层的错误码优先
+		{"outermost", Wrap(Unauthorized, errors.Wrap(AccessDenied, "inner")), -401},
+		{"canceled", context.Canceled, -498},
+		{"deadline", context.DeadlineExceeded, -504},
+		{"pkg wrapped deadline", errors.Wrap(context.DeadlineExceeded, "call"), -504},
+		{"fmt wrapped canceled", fmt.Errorf("call: %w", context.Canceled), -498},
+		{"plain", errors.New("boom"), -500},
+	}
+	for _, cs := range cases {
+		if got := Cause(cs.err).Code(); got != cs.code {
+			t.Errorf("%s: %d, want %d", cs.name, got, cs.code)
+		}
+		if !EqualError(Int(cs.code), cs.err) {
+			t.Errorf("%s: EqualError false", cs.name)
+		}
+	}
+}
+
+func TestCodeMessage(t *testing.T) {
+	if msg := Unauthorized.Message(); msg != "Unauthorized" {
+		t.Errorf("default message: %s", msg)
+	}
+	if msg := _testCode.Message(); msg != "10001" {
+		t.Errorf("unregistered message: %s", msg)
+	}
+	Register(map[int]string{10001: "test", -401: "login required"})
+	defer Register(nil)
+	if msg := _testCode.Message(); msg != "test" {
+		t.Errorf("registered message: %s", msg)
+	}
+	if msg := Unauthorized.Message(); msg != "login required" {
+		t.Errorf("registered common message: %s", msg)
+	}
+}
+
+func TestNewPanics(t *testing.T) {
+	for _, code := range []int{0, -1, 10001} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("New(%d): expected panic", code)
+				}
+			}()
+			New(code)
+		}()
+	}
+}
+
+func TestStatus(t *testing.T) {
+	s := Errorf(RequestErr, "bad %s", "name")
+	if s.Code() != -400 || s.Message() != "bad name" || s.Error() != "bad name" || s.Details() != nil {
+		t.Errorf("status: %d %s %v", s.Code(), s.Message(), s.Details())
+	}
+	// 没有提示信息时使用错误码的提示信息
+	if msg := Error(ServerErr, "").Message(); msg != "Server Error" {
+		t.Errorf("fallback message: %s", msg)
+	}
+	d1 := s.WithDetails("a")
+	d2 := d1.WithDetails("b")
+	d3 := d1.WithDetails("c")
+	if s.Details() != nil || !reflect.DeepEqual(d1.Details(), []interface{}{"a"}) ||
+		!reflect.DeepEqual(d2.Details(), []interface{}{"a", "b"}) || !reflect.DeepEqual(d3.Details(), []interface{}{"a", "c"}) {
+		t.Errorf("details: %v %v %v %v", s.Details(), d1.Details(), d2.Details(), d3.Details())
+	}
+	if Cause(d2).Code() != -400 {
+		t.Errorf("cause of status: %d", Cause(d2).Code())
+	}
+}
+
+func TestWrap(t *testing.T) {
+	if Wrap(ServerErr, nil) != nil {
+		t.Error("Wrap(nil) must be nil")
+	}
+	origin := errors.New("db down")
+	err := Wrap(Error(ServiceUnavailable, "busy"), origin)
+	if err.Error() != "-503: db down" {
+		t.Errorf("error: %s", err)
+	}
+	if errors.Unwrap(err) != origin || !errors.Is(err, origin) {
+		t.Errorf("unwrap: %v", errors.Unwrap(err))
+	}
+	if ec := Cause(err); ec.Code() != -503 || ec.Message() != "busy" {
+		t.Errorf("cause: %d %s", ec.Code(), ec.Message())
+	}
+	// 包装后的错误仍然可以识别原始的ecode
+	if !errors.Is(errors.Wrap(err, "call"), origin) || Cause(errors.Wrap(err, "call")).Code() != -503 {
+		t.Error("wrapped twice")
+	}
+}
@@ -0,0 +1,81 @@
+package ecode
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Status is an ecode with a custom message and details
+// 携带自定义提示信息和详情的错误码
+type Status struct {
+	code    int
+	message string
+	details []interface{}
+}
+
+var _ Codes = &Status{}
+
+// Error new status with code and message
+func Error(code Code, message string) *Status {
+	return &Status{code: code.Code(), message: message}
+}
+
+// Errorf new status with code and format message
+func Errorf(code Code, format string, args ...interface{}) *Status {
+	return Error(code, fmt.Sprintf(format, args...))
+}
+
+// Error implement error
+func (s *Status) Error() string {
+	return s.Message()
+}
+
+// Code return error code
+func (s *Status) Code() int {
+	return s.code
+}
+
+// Message return error message, fallback to the code message
+func (s *Status) Message() string {
+	if s.message == "" {
+		return Code(s.code).Message()
+	}
+	return s.message
+}
+
+// Details return error details
+func (s *Status) Details() []interface{} {
+	return s.details
+}
+
+// WithDetails returns a new status with the details appended
+func (s *Status) WithDetails(details ...interface{}) *Status {
+	ns := *s
+	ns.details = append(append(make([]interface{}, 0, len(s.details)+len(details)), s.details...), details...)
+	return &ns
+}
+
+// wrapped is an error with a code, the original error is kept as the cause
+type wrapped struct {
+	Codes
+	err error
+}
+
+// Wrap annotates err with the code, the code is returned by Cause
+// and the original err can be got by errors.Unwrap.
+// If err is nil, Wrap returns nil.
+func Wrap(code Codes, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &wrapped{Codes: code, err: err}
+}
+
+func (w *wrapped) Error() string {
+	return strconv.Itoa(w.Code()) + ": " + w.err.Error()
+}
+
+// Unwrap return the original error
+func (w *wrapped) Unwrap() error {
+	return w.err
+}
@@ -0,0 +1,109 @@
+package pudding
+
+import (
+	"sync/atomic"
+
+	"github.com/bdjimmy/pudding/ratelimit"
+)
+
+// SetAuthenticator registers the handler verifying the requests of the routes whose MethodConfig.Auth is mode.
+// The handler should render the error and abort the chain if the request is not authenticated
+// 注册鉴权方式对应的处理函数, 校验失败时处理函数需要渲染错误并中断处理链
+func (engine *Engine) SetAuthenticator(mode string, h HandlerFunc) {
+	engine.guardLock.Lock()
+	if engine.authenticators == nil {
+		engine.authenticators = make(map[string]HandlerFunc)
+	}
+	engine.authenticators[mode] = h
+	engine.guardLock.Unlock()
+}
+
+// routeGuard 路由的限流和并发状态, MethodConfig变化时重建.
+// inflight 在重建时沿用, 旧配置下还在处理的请求仍然计入并发数
+type routeGuard struct {
+	mc       *MethodConfig
+	bucket   *ratelimit.TokenBucket
+	inflight *int64
+}
+
+// guardKey 染色路由和默认路由的限流和并发状态相互独立
+type guardKey struct {
+	color string
+	path  string
+}
+
+// guard 在全局中间件之后执行路由的MethodConfig: 缓存策略、鉴权、限流和并发限制,
+// 先鉴权, 未通过鉴权的请求不会消耗路由的配额
+func (engine *Engine) guard(c *Context) {
+	mc := c.methodConfig
+	if mc == nil {
+		return
+	}
+	if mc.CacheControl != "" {
+		c.Writer.Header().Set("Cache-Control", mc.CacheControl)
+	}
+	if mc.Quota <= 0 && mc.MaxConcurrency <= 0 && mc.Auth == "" {
+		return
+	}
+	if mc.Auth != "" {
+		if engine.authenticate(c, mc.Auth); c.IsAborted() {
+			return
+		}
+	}
+	g := engine.routeGuard(guardKey{color: c.routeColor, path: c.RoutePath}, mc)
+	if g.bucket != nil {
+		if _, err := g.bucket.Allow(); err != nil {
+			c.abortLimited(LimiterQuota)
+			return
+		}
+	}
+	if mc.MaxConcurrency <= 0 {
+		return
+	}
+	if atomic.AddInt64(g.inflight, 1) > mc.MaxConcurrency {
+		atomic.AddInt64(g.inflight, -1)
+		c.abortLimited(LimiterConcurrency)
+		return
+	}
+	defer atomic.AddInt64(g.inflight, -1)
+	c.Next()
+}
+
+// authenticate 执行鉴权方式对应的处理函数, 未注册的方式返回401
+func (engine *Engine) authenticate(c *Context, mode string) {
+	engine.guardLock.RLock()
+	h, ok := engine.authenticators[mode]
+	engine.guardLock.RUnlock()
+	if !ok {
+		c.abortUnauthorized("", nil)
+		return
+	}
+	h(c)
+}
+
+// routeGuard 返回路由的限流和并发状态, 配置变化后使用新的状态, 并发数沿用原来的计数
+func (engine *Engine) routeGuard(key guardKey, mc *MethodConfig) *routeGuard {
+	engine.guardLock.RLock()
+	g := engine.guards[key]
+	engine.guardLock.RUnlock()
+	if g != nil && g.mc == mc {
+		return g
+	}
+	engine.guardLock.Lock()
+	defer engine.guardLock.Unlock()
+	old := engine.guards[key]
+	if old != nil && old.mc == mc {
+		return old
+	}
+	g = &routeGuard{mc: mc}
+	if old != nil {
+		g.inflight = old.inflight
+	} else {
+		g.inflight = new(int64)
+	}
+	if mc.Quota > 0 {
+		g.bucket = ratelimit.NewTokenBucket(mc.Quota, mc.Burst)
+	}
+	engine.guards[key] = g
+	return g
+}
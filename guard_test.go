@@ -0,0 +1,189 @@
+package pudding
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// guardServe 发送请求并返回响应体
+func guardServe(engine *Engine, path string, header map[string]string) string {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	return w.Body.String()
+}
+
+func TestGuardAuthBeforeQuota(t *testing.T) {
+	engine := New()
+	engine.SetAuthenticator("token", func(c *Context) {
+		if c.Request.Header.Get("X-Token") != "secret" {
+			c.abortUnauthorized("", nil)
+		}
+	})
+	engine.GET("/q", func(c *Context) { c.String(http.StatusOK, "ok") })
+	engine.SetMethodConfig("/q", &MethodConfig{Quota: 0.001, Burst: 1, Auth: "token"})
+	// 未通过鉴权的请求不消耗配额
+	for i := 0; i < 3; i++ {
+		if body := guardServe(engine, "/q", nil); !strings.Contains(body, "-401") {
+			t.Fatalf("unauthenticated: %s", body)
+		}
+	}
+	auth := map[string]string{"X-Token": "secret"}
+	if body := guardServe(engine, "/q", auth); body != "ok" {
+		t.Fatalf("authenticated request limited: %s", body)
+	}
+	if body := guardServe(engine, "/q", auth); !strings.Contains(body, "-509") {
+		t.Fatalf("quota not applied: %s", body)
+	}
+}
+
+func TestGuardColorRoutes(t *testing.T) {
+	engine := New()
+	ok := func(c *Context) { c.String(http.StatusOK, "ok") }
+	engine.GET("/q", ok)
+	engine.Color("canary").GET("/q", ok)
+	engine.SetMethodConfig("/q", &MethodConfig{Quota: 0.001, Burst: 1})
+	canary := map[string]string{_httpHeaderColor: "canary"}
+	if body := guardServe(engine, "/q", nil); body != "ok" {
+		t.Fatal(body)
+	}
+	// 染色路由有独立的配额
+	if body := guardServe(engine, "/q", canary); body != "ok" {
+		t.Fatalf("canary shares the quota: %s", body)
+	}
+	if body := guardServe(engine, "/q", nil); !strings.Contains(body, "-509") {
+		t.Fatalf("quota not applied: %s", body)
+	}
+	if body := guardServe(engine, "/q", canary); !strings.Contains(body, "-509") {
+		t.Fatalf("canary quota not applied: %s", body)
+	}
+}
+
+func TestGuardReloadKeepsInflight(t *testing.T) {
+	engine := New()
+	started, release := make(chan struct{}), make(chan struct{})
+	engine.GET("/c", func(c *Context) {
+		if c.Query("block") != "" {
+			close(started)
+			<-release
+		}
+		c.String(http.StatusOK, "ok")
+	})
+	engine.SetMethodConfig("/c", &MethodConfig{MaxConcurrency: 1})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		guardServe(engine, "/c?block=1", nil)
+	}()
+	<-started
+	// 重新加载配置后, 旧配置下处理中的请求仍然计入并发数
+	engine.SetMethodConfig("/c", &MethodConfig{MaxConcurrency: 1, CacheControl: "no-store"})
+	if body := guardServe(engine, "/c", nil); !strings.Contains(body, "-509") {
+		t.Fatalf("concurrency exceeded after reload: %s", body)
+	}
+	close(release)
+	wg.Wait()
+	if body := guardServe(engine, "/c", nil); body != "ok" {
+		t.Fatalf("inflight not released: %s", body)
+	}
+}
+
+func TestLoadMethodConfigs(t *testing.T) {
+	engine := New()
+	changes := make(map[string][2]bool)
+	engine.OnMethodConfigChange(func(path string, old, mc *MethodConfig) {
+		changes[path] = [2]bool{old != nil, mc != nil}
+	})
+	// expect 校验一次修改触发的回调, 值为 [旧配置存在, 新配置存在]
+	expect := func(step string, want map[string][2]bool) {
+		if !reflect.DeepEqual(changes, want) {
+			t.Errorf("%s: changes %v, want %v", step, changes, want)
+		}
+		changes = make(map[string][2]bool)
+	}
+	static := &MethodConfig{Quota: 10}
+	engine.SetMethodConfig("/a", static)
+	expect("set", map[string][2]bool{"/a": {false, true}})
+	if err := engine.LoadMethodConfigs(strings.NewReader(`{"/a": {"quota": 20}, "/b/*": {"maxConcurrency": 2}}`)); err != nil {
+		t.Fatal(err)
+	}
+	expect("load", map[string][2]bool{"/a": {true, true}, "/b/*": {false, true}})
+	if mc := engine.methodConfig("/a"); mc == nil || mc.Quota != 20 {
+		t.Fatalf("loaded config doesn't take precedence: %+v", mc)
+	}
+	if mc := engine.methodConfig("/b/x"); mc == nil || mc.MaxConcurrency != 2 {
+		t.Fatalf("prefix config: %+v", mc)
+	}
+	loaded := engine.methodConfig("/b/x")
+	// 内容相同的配置保留原来的对象, 限流和并发状态不会被重置; 不在文件中的路径回落到代码中的配置
+	if err := engine.LoadMethodConfigs(strings.NewReader(`{"/b/*": {"maxConcurrency": 2}}`)); err != nil {
+		t.Fatal(err)
+	}
+	expect("reload", map[string][2]bool{"/a": {true, true}})
+	if engine.methodConfig("/b/x") != loaded {
+		t.Fatal("unchanged config replaced")
+	}
+	if engine.methodConfig("/a") != static {
+		t.Fatal("static config not restored")
+	}
+	if err := engine.LoadMethodConfigs(strings.NewReader(`{"/a":`)); err == nil {
+		t.Fatal("malformed configs loaded")
+	}
+	engine.ReloadMethodConfigs(nil)
+	expect("remove", map[string][2]bool{"/b/*": {true, false}})
+}
+
+func TestWatchMethodConfigFile(t *testing.T) {
+	engine := New()
+	file := filepath.Join(t.TempDir(), "method.json")
+	write := func(s string, mtime time.Time) {
+		if err := os.WriteFile(file, []byte(s), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(file, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	reloaded := make(chan *MethodConfig, 4)
+	engine.OnMethodConfigChange(func(path string, old, mc *MethodConfig) { reloaded <- mc })
+	now := time.Now()
+	write(`{"/w": {"quota": 1}}`, now)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := engine.WatchMethodConfigFile(ctx, file, 5*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if mc := <-reloaded; mc == nil || mc.Quota != 1 {
+		t.Fatalf("first load: %+v", mc)
+	}
+	// 加载失败时保留原来的配置
+	write(`{"/w":`, now.Add(time.Second))
+	time.Sleep(30 * time.Millisecond)
+	if mc := engine.methodConfig("/w"); mc == nil || mc.Quota != 1 {
+		t.Fatalf("config lost after a bad reload: %+v", mc)
+	}
+	write(`{"/w": {"quota": 2}}`, now.Add(2*time.Second))
+	select {
+	case mc := <-reloaded:
+		if mc == nil || mc.Quota != 2 {
+			t.Fatalf("reload: %+v", mc)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("file not reloaded")
+	}
+	if err := engine.WatchMethodConfigFile(ctx, file+".missing", time.Second); err == nil {
+		t.Fatal("missing file watched")
+	}
+}
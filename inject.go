@@ -0,0 +1,168 @@
+package pudding
+
+import (
+	"regexp"
+	"sort"
+)
+
+// injection 通过正则注册的公共中间件
+type injection struct {
+	pattern  *regexp.Regexp
+	handlers []HandlerFunc
+	// priority 小的先执行, 相同时按注册顺序执行
+	priority int
+	seq      int
+}
+
+// InjectionInfo describes an injection applied to a route
+type InjectionInfo struct {
+	Pattern  string   `json:"pattern"`
+	Priority int      `json:"priority"`
+	Handlers []string `json:"handlers"`
+}
+
+// routeEntry 注册的路由, 注册新的injection后根据它重新生成处理链
+type routeEntry struct {
+	method string
+	path   string
+	color  string
+	// group 注册时group的中间件, 开头的globals个是全局中间件
+	group    []HandlerFunc
+	globals  int
+	handlers []HandlerFunc
+}
+
+// Inject registers the middleware for the routes whose path matches the pattern, with priority 0
+// 正则注册中间件
+func (engine *Engine) Inject(pattern string, handlers ...HandlerFunc) {
+	engine.InjectPriority(pattern, 0, handlers...)
+}
+
+// InjectPriority registers the middleware for the routes whose path matches the pattern.
+// All the matching injections are stacked after the group middleware, the lower priority runs first
+// and the same priority runs in the registration order.
+// The routes registered before are re-evaluated, so it must be called before serving,
+// it panics after Start, Run or RunServer since the route trees are read without locking
+// 所有匹配的injection按priority从小到大执行, 已经注册的路由会重新生成处理链, 需要在启动服务前调用,
+// 路由树在处理请求时不加锁读取, 启动服务后调用会panic
+func (engine *Engine) InjectPriority(pattern string, priority int, handlers ...HandlerFunc) {
+	if engine.Server() != nil {
+		panic("pudding: Inject must be called before the server starts")
+	}
+	engine.injections = append(engine.injections, injection{
+		pattern:  regexp.MustCompile(pattern),
+		handlers: handlers,
+		priority: priority,
+		seq:      len(engine.injections),
+	})
+	sort.SliceStable(engine.injections, func(i, j int) bool {
+		if engine.injections[i].priority != engine.injections[j].priority {
+			return engine.injections[i].priority < engine.injections[j].priority
+		}
+		return engine.injections[i].seq < engine.injections[j].seq
+	})
+	engine.rebuildRoutes()
+}
+
+// RouteInjections returns the injections applied to the route in the execution order
+func (engine *Engine) RouteInjections(method, path string) []InjectionInfo {
+	var infos []InjectionInfo
+	for _, r := range engine.routes {
+		if r.method != method || r.path != path {
+			continue
+		}
+		for _, inj := range engine.matchInjections(r.path) {
+			info := InjectionInfo{Pattern: inj.pattern.String(), Priority: inj.priority}
+			for _, h := range inj.handlers {
+				info.Handlers = append(info.Handlers, nameOfFunction(h))
+			}
+			infos = append(infos, info)
+		}
+		break
+	}
+	return infos
+}
+
+// matchInjections 返回匹配路径的所有injection, 已经按执行顺序排好
+func (engine *Engine) matchInjections(path string) (matched []*injection) {
+	for i := range engine.injections {
+		if engine.injections[i].pattern.MatchString(path) {
+			matched = append(matched, &engine.injections[i])
+		}
+	}
+	return
+}
+
+// addRouteEntry 生成处理链并注册路由
+func (engine *Engine) addRouteEntry(r *routeEntry) {
+	engine.mountRoute(r)
+	engine.routes = append(engine.routes, r)
+}
+
+// mountRoute 把路由的处理链和预检请求的处理链加入路由树
+func (engine *Engine) mountRoute(r *routeEntry) {
+	chain := engine.routeChain(r)
+	engine.addColorRoute(r.color, r.method, r.path, chain...)
+	if engine.preflights == nil {
+		engine.preflights = make(map[string][]HandlerFunc)
+	}
+	engine.preflights[preflightKey(r.color, r.method, r.path)] = engine.preflightChain(r)
+}
+
+// routeChain 生成路由的处理链: 全局中间件, engine.guard, group中间件, injection, 处理函数.
+// engine.guard 在全局中间件之后执行, 全局中间件可以记录被限流和拒绝的请求
+func (engine *Engine) routeChain(r *routeEntry) []HandlerFunc {
+	injections := engine.matchInjections(r.path)
+	size := len(r.group) + 1 + len(r.handlers)
+	for _, inj := range injections {
+		size += len(inj.handlers)
+	}
+	if size >= int(_abortIndex) {
+		panic("too many handlers")
+	}
+	chain := make([]HandlerFunc, 0, size)
+	chain = append(chain, r.group[:r.globals]...)
+	chain = append(chain, engine.guard)
+	chain = append(chain, r.group[r.globals:]...)
+	for _, inj := range injections {
+		chain = append(chain, inj.handlers...)
+	}
+	return append(chain, r.handlers...)
+}
+
+// preflightChain 生成预检请求的处理链: 全局中间件、group中间件和injection中的CORS中间件, 自动应答.
+// 预检请求不带凭证, 不执行engine.guard和其他中间件, 例如鉴权中间件
+func (engine *Engine) preflightChain(r *routeEntry) []HandlerFunc {
+	var chain []HandlerFunc
+	for _, h := range r.group {
+		if isCORS(h) {
+			chain = append(chain, h)
+		}
+	}
+	for _, inj := range engine.matchInjections(r.path) {
+		for _, h := range inj.handlers {
+			if isCORS(h) {
+				chain = append(chain, h)
+			}
+		}
+	}
+	return append(chain, defaultOptions)
+}
+
+// preflightKey 预检请求处理链的key
+func preflightKey(color, method, path string) string {
+	return color + "\x00" + method + " " + path
+}
+
+// rebuildRoutes 根据已注册的路由重新生成路由树
+func (engine *Engine) rebuildRoutes() {
+	if len(engine.routes) == 0 {
+		return
+	}
+	engine.trees = make(methodTrees, 0, len(engine.trees))
+	engine.colorTrees = nil
+	engine.preflights = nil
+	for _, r := range engine.routes {
+		engine.mountRoute(r)
+	}
+}
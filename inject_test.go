@@ -0,0 +1,74 @@
+package pudding
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bdjimmy/pudding/utils"
+)
+
+// traceHandler 返回记录执行顺序的中间件
+func traceHandler(name string) HandlerFunc {
+	return func(c *Context) {
+		v, _ := c.Get("trace")
+		s, _ := v.(string)
+		c.Set("trace", s+name+",")
+	}
+}
+
+func TestInjectStackedPriority(t *testing.T) {
+	engine := New()
+	final := func(c *Context) {
+		v, _ := c.Get("trace")
+		c.String(http.StatusOK, v.(string)+"handler")
+	}
+	// 先注册的路由也会应用之后注册的injection
+	engine.GET("/api/user", traceHandler("route"), final)
+	engine.Inject("^/api/", traceHandler("api"))
+	engine.InjectPriority("^/api/user$", -1, traceHandler("user"))
+	engine.InjectPriority(".*", 1, traceHandler("all"))
+	engine.Inject("^/api/", traceHandler("api2"))
+	engine.Inject("^/other", traceHandler("other"))
+	engine.GET("/api/later", traceHandler("route"), final)
+
+	cases := []struct {
+		path string
+		want string
+	}{
+		{"/api/user", "user,api,api2,all,route,handler"},
+		{"/api/later", "api,api2,all,route,handler"},
+	}
+	for _, cs := range cases {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, cs.path, nil))
+		if w.Body.String() != cs.want {
+			t.Errorf("%s: %s, want %s", cs.path, w.Body.String(), cs.want)
+		}
+	}
+	infos := engine.RouteInjections(http.MethodGet, "/api/user")
+	var patterns []string
+	for _, info := range infos {
+		patterns = append(patterns, info.Pattern)
+	}
+	if strings.Join(patterns, " ") != "^/api/user$ ^/api/ ^/api/ .*" {
+		t.Fatalf("route injections: %v", patterns)
+	}
+}
+
+func TestInjectAfterStart(t *testing.T) {
+	engine := NewServer(&ServerConfig{Address: "127.0.0.1:0", TimeOut: utils.Duration(time.Second)})
+	if err := engine.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer engine.ShutDown(context.Background())
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Inject after Start doesn't panic")
+		}
+	}()
+	engine.Inject(".*", func(c *Context) {})
+}
@@ -0,0 +1,124 @@
+package pudding
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// 没有配置ShutdownTimeOut时, 优雅退出等待活动连接结束的最长时间
+	defaultShutdownTimeout = 30 * time.Second
+)
+
+// Hook is called when the engine starts or shuts down
+type Hook func(ctx context.Context) error
+
+// OnStart registers hooks called in order before the engine starts listening,
+// the engine won't start if any hook returns an error
+func (engine *Engine) OnStart(hooks ...Hook) {
+	engine.hookLock.Lock()
+	engine.onStart = append(engine.onStart, hooks...)
+	engine.hookLock.Unlock()
+}
+
+// OnShutdown registers hooks called in order after the server is shut down
+func (engine *Engine) OnShutdown(hooks ...Hook) {
+	engine.hookLock.Lock()
+	engine.onShutdown = append(engine.onShutdown, hooks...)
+	engine.hookLock.Unlock()
+}
+
+// Errors returns the channel of the listener and server errors after Start
+// 服务运行中的错误通过channel返回, 不再panic
+func (engine *Engine) Errors() <-chan error {
+	return engine.errCh
+}
+
+// Ready reports whether the engine is not draining, it's used by the health check.
+// It doesn't depend on the http.Server, so the engine served by Router, httptest or
+// a user-owned http.Server is ready too
+// 退出中返回false, 健康检查会返回未就绪
+func (engine *Engine) Ready() bool {
+	return atomic.LoadInt32(&engine.draining) == 0
+}
+
+// Serve starts the engine and blocks until ctx is done, SIGTERM/SIGINT is received or the server fails.
+// Then it gracefully shuts down the engine:
+// 1. the health check reports not-ready during the drain period
+// 2. ShutDown waits for the active connections within the shutdown timeout
+// 3. the OnShutdown hooks are called
+func (engine *Engine) Serve(ctx context.Context) (err error) {
+	if err = engine.Start(); err != nil {
+		return
+	}
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGTERM, syscall.SIGINT)
+	defer signal.Stop(ch)
+
+	select {
+	case <-ctx.Done():
+		log.Printf("pudding: context done, shutting down: %v", ctx.Err())
+	case sig := <-ch:
+		log.Printf("pudding: get a signal %s, shutting down", sig.String())
+	case err = <-engine.errCh:
+		// 服务已经异常退出, 只执行退出钩子
+		if herr := engine.runHooks(context.Background(), false); herr != nil {
+			log.Printf("pudding: shutdown hook error(%+v)", herr)
+		}
+		return
+	}
+	return engine.gracefulStop()
+}
+
+// gracefulStop 先摘除流量再关闭服务
+func (engine *Engine) gracefulStop() error {
+	engine.lock.RLock()
+	drain := time.Duration(engine.conf.Drain)
+	timeout := time.Duration(engine.conf.ShutdownTimeOut)
+	engine.lock.RUnlock()
+	if timeout <= 0 {
+		timeout = defaultShutdownTimeout
+	}
+
+	atomic.StoreInt32(&engine.draining, 1)
+	if drain > 0 {
+		log.Printf("pudding: draining for %s", drain)
+		time.Sleep(drain)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return engine.ShutDown(ctx)
+}
+
+// runHooks 按顺序执行启动或者退出的钩子, 遇到错误立即返回
+func (engine *Engine) runHooks(ctx context.Context, start bool) error {
+	engine.hookLock.Lock()
+	hooks := engine.onShutdown
+	if start {
+		hooks = engine.onStart
+	}
+	hooks = append([]Hook(nil), hooks...)
+	engine.hookLock.Unlock()
+	for _, hook := range hooks {
+		if err := hook(ctx); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+// reportError 把服务运行中的错误发送到channel, channel已满时只记录日志
+func (engine *Engine) reportError(err error) {
+	select {
+	case engine.errCh <- err:
+	default:
+		log.Printf("pudding: server error(%+v)", err)
+	}
+}
@@ -0,0 +1,41 @@
+package pudding
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bdjimmy/pudding/utils"
+)
+
+func TestStartShutDown(t *testing.T) {
+	engine := NewServer(&ServerConfig{Address: "127.0.0.1:0", TimeOut: utils.Duration(time.Second)})
+	if err := engine.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if engine.Server() == nil {
+		t.Fatal("server is not stored when Start returns")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := engine.ShutDown(ctx); err != nil {
+		t.Fatalf("shutdown right after start: %+v", err)
+	}
+}
+
+func TestPingWithoutServer(t *testing.T) {
+	engine := New()
+	engine.Ping(func(c *Context) { c.Status(http.StatusOK) })
+	srv := httptest.NewServer(engine)
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + "/monitor/ping")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("ping status: %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,254 @@
+package pudding
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bdjimmy/pudding/ecode"
+	"github.com/bdjimmy/pudding/metadata"
+	"github.com/bdjimmy/pudding/metrics"
+	"github.com/bdjimmy/pudding/utils"
+	"github.com/pkg/errors"
+)
+
+// LogRecord is the access log record of a request
+// 每个请求一条访问日志
+type LogRecord struct {
+	Time       time.Time     `json:"time"`
+	Method     string        `json:"method"`
+	Path       string        `json:"path"`
+	Route      string        `json:"route"`
+	Status     int           `json:"status"`
+	Bytes      int           `json:"bytes"`
+	Latency    time.Duration `json:"-"`
+	RemoteIP   string        `json:"remote_ip"`
+	RemotePort string        `json:"remote_port"`
+	Caller     string        `json:"caller"`
+	TraceID    string        `json:"trace_id,omitempty"`
+	Mirror     bool          `json:"mirror"`
+	Code       int           `json:"code"`
+	Error      string        `json:"error,omitempty"`
+	Slow       bool          `json:"slow"`
+}
+
+// LogEncoder encodes the access log record into a line
+type LogEncoder interface {
+	Encode(r *LogRecord) ([]byte, error)
+}
+
+// LogSink is where the encoded access log lines go
+type LogSink interface {
+	Write(r *LogRecord, line []byte) error
+}
+
+// LoggerConfig is the access log config
+type LoggerConfig struct {
+	// Encoder 默认logfmt格式
+	Encoder LogEncoder
+	// Sink 默认输出到标准错误
+	Sink LogSink
+	// Sample 正常请求的采样率, (0, 1) 之间生效, 出错和慢请求总是会记录
+	Sample float64
+	// Slow 默认的慢请求阈值, 路由的MethodConfig.Slow优先
+	Slow utils.Duration
+}
+
+// 编码或写入失败的访问日志数, 失败不影响请求处理
+var _metricLogErrors = metrics.NewCounterVec(&metrics.Opts{
+	Namespace: "http_server",
+	Subsystem: "access_log",
+	Name:      "errors_total",
+	Help:      "http server access log records dropped by stage(encode, write).",
+	Labels:    []string{"stage"},
+})
+
+// Logger returns the access log middleware which emits one record per request.
+// It should be registered before Recovery, otherwise the panicking requests unwind through
+// the logger and are never logged, e.g. New() then UseFunc(Logger(conf), Recovery()).
+// The records failed to encode or write are dropped and counted by
+// http_server_access_log_errors_total, the first failure is reported to the standard error
+// 访问日志中间件, 需要在Recovery之前注册, 否则panic的请求不会记录日志;
+// 编码或写入失败时丢弃日志并计数, 第一次失败输出到标准错误
+func Logger(conf *LoggerConfig) HandlerFunc {
+	if conf == nil {
+		conf = &LoggerConfig{}
+	}
+	encoder, sink := conf.Encoder, conf.Sink
+	if encoder == nil {
+		encoder = LogfmtEncoder{}
+	}
+	if sink == nil {
+		sink = NewWriterSink(os.Stderr)
+	}
+	sampler := newSampler(conf.Sample)
+	var reportOnce sync.Once
+	drop := func(stage string, err error) {
+		_metricLogErrors.Inc(stage)
+		reportOnce.Do(func() {
+			fmt.Fprintf(os.Stderr, "pudding: access log %s error: %v, the following errors are only counted\n", stage, err)
+		})
+	}
+	return func(c *Context) {
+		start := time.Now()
+		c.Next()
+
+		slow := time.Duration(conf.Slow)
+		if mc := c.MethodConfig(); mc.Slow > 0 {
+			slow = time.Duration(mc.Slow)
+		}
+		r := newLogRecord(c, start)
+		r.Slow = slow > 0 && r.Latency >= slow
+		// 出错和慢请求总是记录, 其他的按采样率记录
+		if r.Status < 500 && r.Error == "" && !r.Slow && !sampler.sample() {
+			return
+		}
+		line, err := encoder.Encode(r)
+		if err != nil {
+			drop("encode", err)
+			return
+		}
+		if err = sink.Write(r, line); err != nil {
+			drop("write", err)
+		}
+	}
+}
+
+func newLogRecord(c *Context, start time.Time) *LogRecord {
+	req := c.Request
+	r := &LogRecord{
+		Time:    start,
+		Method:  req.Method,
+		Path:    req.URL.Path,
+		Route:   c.RoutePath,
+		Status:  200,
+		Latency: time.Since(start),
+	}
+	if rw, ok := c.Writer.(ResponseWriter); ok {
+		r.Status = rw.Status()
+		r.Bytes = rw.Size()
+	}
+	if md, ok := metadata.FromContext(c); ok {
+		r.RemoteIP, _ = md[metadata.RemoteIP].(string)
+		r.RemotePort, _ = md[metadata.RemotePort].(string)
+		r.Caller, _ = md[metadata.Caller].(string)
+		r.TraceID, _ = md[metadata.TraceID].(string)
+		r.Mirror, _ = md[metadata.Mirror].(bool)
+	}
+	// 业务码优先取render.JSON中的code
+	if code, err := strconv.Atoi(c.Writer.Header().Get(_httpHeaderStatusCode)); err == nil {
+		r.Code = code
+	} else {
+		r.Code = ecode.Cause(c.Error).Code()
+	}
+	if c.Error != nil {
+		r.Error = c.Error.Error()
+	}
+	return r
+}
+
+// JSONEncoder encodes the record as a JSON object
+type JSONEncoder struct{}
+
+// Encode implements LogEncoder
+func (JSONEncoder) Encode(r *LogRecord) ([]byte, error) {
+	type alias LogRecord
+	bs, err := json.Marshal(&struct {
+		*alias
+		Latency float64 `json:"latency"`
+	}{alias: (*alias)(r), Latency: r.Latency.Seconds()})
+	return bs, errors.WithStack(err)
+}
+
+// LogfmtEncoder encodes the record as key=value pairs
+type LogfmtEncoder struct{}
+
+// Encode implements LogEncoder
+func (LogfmtEncoder) Encode(r *LogRecord) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	writeLogfmt(buf, "time", r.Time.Format(time.RFC3339Nano))
+	writeLogfmt(buf, "method", r.Method)
+	writeLogfmt(buf, "path", r.Path)
+	writeLogfmt(buf, "route", r.Route)
+	writeLogfmt(buf, "status", strconv.Itoa(r.Status))
+	writeLogfmt(buf, "bytes", strconv.Itoa(r.Bytes))
+	writeLogfmt(buf, "latency", strconv.FormatFloat(r.Latency.Seconds(), 'f', -1, 64))
+	writeLogfmt(buf, "remote_ip", r.RemoteIP)
+	writeLogfmt(buf, "remote_port", r.RemotePort)
+	writeLogfmt(buf, "caller", r.Caller)
+	if r.TraceID != "" {
+		writeLogfmt(buf, "trace_id", r.TraceID)
+	}
+	writeLogfmt(buf, "mirror", strconv.FormatBool(r.Mirror))
+	writeLogfmt(buf, "code", strconv.Itoa(r.Code))
+	if r.Error != "" {
+		writeLogfmt(buf, "error", r.Error)
+	}
+	writeLogfmt(buf, "slow", strconv.FormatBool(r.Slow))
+	return buf.Bytes(), nil
+}
+
+func writeLogfmt(buf *bytes.Buffer, key, value string) {
+	if buf.Len() > 0 {
+		buf.WriteByte(' ')
+	}
+	buf.WriteString(key)
+	buf.WriteByte('=')
+	if value == "" || strings.ContainsAny(value, " =\"\t\r\n") {
+		buf.WriteString(strconv.Quote(value))
+		return
+	}
+	buf.WriteString(value)
+}
+
+// writerSink 把日志逐行写入io.Writer
+type writerSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewWriterSink returns a LogSink writing one line per record into w
+func NewWriterSink(w io.Writer) LogSink {
+	return &writerSink{w: w}
+}
+
+func (s *writerSink) Write(r *LogRecord, line []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.w.Write(line); err != nil {
+		return errors.WithStack(err)
+	}
+	_, err := s.w.Write([]byte{'\n'})
+	return errors.WithStack(err)
+}
+
+// sampler 按比例采样
+type sampler struct {
+	rate float64
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+func newSampler(rate float64) *sampler {
+	return &sampler{
+		rate: rate,
+		rand: rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+func (s *sampler) sample() bool {
+	if s.rate <= 0 || s.rate >= 1 {
+		return true
+	}
+	s.mu.Lock()
+	f := s.rand.Float64()
+	s.mu.Unlock()
+	return f < s.rate
+}
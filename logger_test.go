@@ -0,0 +1,65 @@
+package pudding
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+type failingSink struct{}
+
+func (failingSink) Write(*LogRecord, []byte) error { return errors.New("disk full") }
+
+type failingEncoder struct{}
+
+func (failingEncoder) Encode(*LogRecord) ([]byte, error) { return nil, errors.New("bad record") }
+
+type recordSink struct{ records []*LogRecord }
+
+func (s *recordSink) Write(r *LogRecord, _ []byte) error {
+	s.records = append(s.records, r)
+	return nil
+}
+
+func TestLoggerErrorsCounted(t *testing.T) {
+	cases := []struct {
+		stage string
+		conf  *LoggerConfig
+	}{
+		{"write", &LoggerConfig{Sink: failingSink{}}},
+		{"encode", &LoggerConfig{Encoder: failingEncoder{}, Sink: failingSink{}}},
+	}
+	for _, cs := range cases {
+		engine := New()
+		engine.UseFunc(Logger(cs.conf))
+		engine.GET("/log", func(c *Context) { c.String(http.StatusOK, "ok") })
+		before := _metricLogErrors.Value(cs.stage)
+		for i := 0; i < 3; i++ {
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/log", nil))
+			if w.Code != http.StatusOK || w.Body.String() != "ok" {
+				t.Fatalf("%s: %d %s", cs.stage, w.Code, w.Body.String())
+			}
+		}
+		if got := _metricLogErrors.Value(cs.stage) - before; got != 3 {
+			t.Errorf("%s errors: %v, want 3", cs.stage, got)
+		}
+	}
+}
+
+func TestLoggerBeforeRecovery(t *testing.T) {
+	sink := &recordSink{}
+	engine := New()
+	engine.UseFunc(Logger(&LoggerConfig{Sink: sink}), Recovery())
+	engine.GET("/panic", func(c *Context) { panic("boom") })
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/panic", nil))
+	if len(sink.records) != 1 {
+		t.Fatalf("records: %d", len(sink.records))
+	}
+	if r := sink.records[0]; r.Status != w.Code || r.Code != -500 || r.Error == "" || r.Route != "/panic" {
+		t.Errorf("record: %+v", r)
+	}
+}
@@ -2,19 +2,23 @@ package main
 
 import (
 	"github.com/bdjimmy/pudding"
+	"github.com/bdjimmy/pudding/utils"
 	"fmt"
 	"time"
 )
 
 func main(){
-	engine := pudding.Default()
+	engine := pudding.New()
 
-	// 添加中间件
+	// 添加中间件, 访问日志需要在Recovery之前注册, panic的请求才会记录日志
+	engine.UseFunc(pudding.Logger(&pudding.LoggerConfig{
+		Encoder: pudding.JSONEncoder{},
+		Slow:    utils.Duration(100 * time.Millisecond),
+	}))
+	engine.UseFunc(pudding.Recovery())
+	engine.Metrics("/metrics")
 	engine.UseFunc(func(c *pudding.Context) {
-		start := time.Now()
 		c.Set("log_id", 123)
-		c.Next()
-		fmt.Printf("cost: %v\n", time.Since(start))
 	})
 
 	// 注册公共中间件
@@ -35,7 +39,7 @@ func main(){
 	outGroup := engine.Group("/external")
 	outGroup.GET("/test", func(c *pudding.Context) {
 		logId, _ := c.Get("log_id")
-		c.JSON(0, "success", fmt.Sprintf("hello world!, logid=%v", logId))
+		c.JSON(fmt.Sprintf("hello world!, logid=%v", logId), nil)
 	})
 
 	// 启动Server
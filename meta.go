@@ -0,0 +1,293 @@
+package pudding
+
+import (
+	"mime/multipart"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bdjimmy/pudding/rbac"
+	"github.com/bdjimmy/pudding/utils"
+)
+
+// the common metadata keys of the routes
+// 路由的常用元数据
+const (
+	MetaDescription = "description"
+	MetaOwner       = "owner"
+	MetaTimeout     = "timeout"
+	MetaAuth        = "auth"
+	MetaRequest     = "request"
+	MetaResponse    = "response"
+	MetaRoles       = "roles"
+	MetaPermissions = "permissions"
+)
+
+// RouteMeta is the common metadata of a route, the empty fields are ignored
+type RouteMeta struct {
+	// Description 路由的描述
+	Description string
+	// Owner 负责人
+	Owner string
+	// Timeout 超时时间, 为0时使用MethodConfig中的配置
+	Timeout utils.Duration
+	// Auth 鉴权等级, 例如 public, login, admin
+	Auth string
+	// Request 请求的结构体, 例如 &ArgUser{}, 会转换成schema
+	Request interface{}
+	// Response 响应中data的结构体, 会转换成schema
+	Response interface{}
+	// Roles 调用方需要拥有其中任意一个角色, 由Authorize中间件校验
+	Roles []string
+	// Permissions 调用方需要拥有所有的权限, 由Authorize中间件校验
+	Permissions []string
+}
+
+// Meta attaches the metadata to the route at relativePath under the group
+// 为路由设置元数据, 通过 /metadata 和 /register 导出
+func (group *RouterGroup) Meta(relativePath string, meta *RouteMeta) IRoutes {
+	path := group.calculateAbsolutePath(relativePath)
+	if meta.Description != "" {
+		group.engine.SetMetadata(path, MetaDescription, meta.Description)
+	}
+	if meta.Owner != "" {
+		group.engine.SetMetadata(path, MetaOwner, meta.Owner)
+	}
+	if meta.Timeout > 0 {
+		group.engine.SetMetadata(path, MetaTimeout, time.Duration(meta.Timeout).String())
+	}
+	if meta.Auth != "" {
+		group.engine.SetMetadata(path, MetaAuth, meta.Auth)
+	}
+	if meta.Request != nil {
+		group.engine.SetMetadata(path, MetaRequest, Schema(meta.Request))
+	}
+	if meta.Response != nil {
+		group.engine.SetMetadata(path, MetaResponse, Schema(meta.Response))
+	}
+	// 角色和权限同时按group的染色标记记录, 由Authorize校验
+	if len(meta.Roles) > 0 {
+		group.engine.SetMetadata(path, MetaRoles, append([]string(nil), meta.Roles...))
+		group.engine.require(group.color, _anyMethod, path, func(req *rbac.Requirement) {
+			req.Roles = append([]string(nil), meta.Roles...)
+		})
+	}
+	if len(meta.Permissions) > 0 {
+		group.engine.SetMetadata(path, MetaPermissions, append([]string(nil), meta.Permissions...))
+		group.engine.require(group.color, _anyMethod, path, func(req *rbac.Requirement) {
+			req.Permissions = append([]string(nil), meta.Permissions...)
+		})
+	}
+	return group.returnObj()
+}
+
+// SetMetadata sets the metadata value by key on the route path, e.g. /user/:id
+func (engine *Engine) SetMetadata(path, key string, value interface{}) {
+	engine.metaLock.Lock()
+	meta, ok := engine.metastore[path]
+	if !ok {
+		meta = make(map[string]interface{})
+		engine.metastore[path] = meta
+	}
+	meta[key] = value
+	engine.metaLock.Unlock()
+}
+
+// Metadata returns a copy of the metadata of the route path, nil if the path is unknown
+func (engine *Engine) Metadata(path string) map[string]interface{} {
+	engine.metaLock.RLock()
+	defer engine.metaLock.RUnlock()
+	meta, ok := engine.metastore[path]
+	if !ok {
+		return nil
+	}
+	return copyMeta(meta)
+}
+
+func copyMeta(meta map[string]interface{}) map[string]interface{} {
+	cp := make(map[string]interface{}, len(meta))
+	for k, v := range meta {
+		cp[k] = v
+	}
+	return cp
+}
+
+// RegisterRoute is a route in the discovery document served from /register
+type RegisterRoute struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	// Color 染色路由的染色标记, 默认路由为空
+	Color string                 `json:"color,omitempty"`
+	Meta  map[string]interface{} `json:"meta,omitempty"`
+}
+
+// RegisterDocument is the discovery document served from /register,
+// the gateway imports the routes from it
+// 服务发现的文档, 网关据此自动导入路由
+type RegisterDocument struct {
+	Routes []*RegisterRoute `json:"routes"`
+}
+
+// register 返回服务发现文档, 包括染色路由, 路由按path、method和染色标记排序
+func (engine *Engine) register() HandlerFunc {
+	return func(c *Context) {
+		c.JSON(engine.registerDocument(), nil)
+	}
+}
+
+func (engine *Engine) registerDocument() *RegisterDocument {
+	doc := &RegisterDocument{Routes: make([]*RegisterRoute, 0)}
+	engine.metaLock.RLock()
+	defer engine.metaLock.RUnlock()
+	add := func(color string, trees methodTrees) {
+		for path, meta := range engine.metastore {
+			for _, tree := range trees {
+				// 路由模板本身也能匹配到路由, 通过fullPath确认是同一个路由
+				if value := tree.root.getValue(path, nil); value.handlers == nil || value.fullPath != path {
+					continue
+				}
+				route := &RegisterRoute{Method: tree.method, Path: path, Color: color}
+				if len(meta) > 0 {
+					route.Meta = copyMeta(meta)
+				}
+				if _, ok := route.Meta[MetaTimeout]; !ok {
+					if mc := engine.methodConfig(path); mc != nil && mc.Timeout > 0 {
+						if route.Meta == nil {
+							route.Meta = make(map[string]interface{})
+						}
+						route.Meta[MetaTimeout] = time.Duration(mc.Timeout).String()
+					}
+				}
+				doc.Routes = append(doc.Routes, route)
+			}
+		}
+	}
+	add("", engine.trees)
+	for color, trees := range engine.colorTrees {
+		add(color, trees)
+	}
+	sort.Slice(doc.Routes, func(i, j int) bool {
+		if doc.Routes[i].Path != doc.Routes[j].Path {
+			return doc.Routes[i].Path < doc.Routes[j].Path
+		}
+		if doc.Routes[i].Method != doc.Routes[j].Method {
+			return doc.Routes[i].Method < doc.Routes[j].Method
+		}
+		return doc.Routes[i].Color < doc.Routes[j].Color
+	})
+	return doc
+}
+
+var (
+	_timeType       = reflect.TypeOf(time.Time{})
+	_durationType   = reflect.TypeOf(utils.Duration(0))
+	_fileHeaderType = reflect.TypeOf(multipart.FileHeader{})
+)
+
+// Schema describes the type of v as a JSON schema, e.g. the request and the response of a route.
+// The field names are taken from the json tags, then the form tags,
+// and the fields with validate:"required" are listed in required
+// 把结构体转换成JSON schema, 字段名依次取json和form标签
+func Schema(v interface{}) map[string]interface{} {
+	return schema(reflect.TypeOf(v), make(map[reflect.Type]bool))
+}
+
+func schema(t reflect.Type, seen map[reflect.Type]bool) map[string]interface{} {
+	if t == nil {
+		return map[string]interface{}{}
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t {
+	case _timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case _durationType:
+		return map[string]interface{}{"type": "string", "format": "duration"}
+	case _fileHeaderType:
+		return map[string]interface{}{"type": "string", "format": "binary"}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{"type": "array", "items": schema(t.Elem(), seen)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schema(t.Elem(), seen)}
+	case reflect.Struct:
+		// 递归的结构体只展开一次
+		if seen[t] {
+			return map[string]interface{}{"type": "object"}
+		}
+		seen[t] = true
+		defer delete(seen, t)
+		props := make(map[string]interface{})
+		var required []string
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			if sf.PkgPath != "" && !sf.Anonymous {
+				continue
+			}
+			name, ok := schemaName(sf)
+			if !ok {
+				continue
+			}
+			// 匿名结构体的字段提升到外层
+			if sf.Anonymous && name == "" {
+				if sub := schema(sf.Type, seen); sub["properties"] != nil {
+					for k, v := range sub["properties"].(map[string]interface{}) {
+						props[k] = v
+					}
+					if r, ok := sub["required"].([]string); ok {
+						required = append(required, r...)
+					}
+				}
+				continue
+			}
+			if name == "" {
+				name = sf.Name
+			}
+			props[name] = schema(sf.Type, seen)
+			for _, rule := range strings.Split(sf.Tag.Get("validate"), ",") {
+				if strings.TrimSpace(rule) == "required" {
+					required = append(required, name)
+				}
+			}
+		}
+		s := map[string]interface{}{"type": "object", "properties": props}
+		if len(required) > 0 {
+			s["required"] = required
+		}
+		return s
+	}
+	return map[string]interface{}{}
+}
+
+// schemaName 返回字段名, 标签为"-"时忽略该字段
+func schemaName(sf reflect.StructField) (string, bool) {
+	for _, key := range []string{"json", "form"} {
+		tag, ok := sf.Tag.Lookup(key)
+		if !ok {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		if name == "-" {
+			return "", false
+		}
+		if name != "" {
+			return name, true
+		}
+	}
+	return "", true
+}
@@ -0,0 +1,29 @@
+package pudding
+
+import "testing"
+
+func TestRegisterDocumentColorRoutes(t *testing.T) {
+	engine := New()
+	engine.GET("/both", func(c *Context) {})
+	canary := engine.Color("canary")
+	canary.GET("/both", func(c *Context) {})
+	canary.POST("/canary", func(c *Context) {})
+	canary.Meta("/canary", &RouteMeta{Owner: "alice"})
+	doc := engine.registerDocument()
+	want := []RegisterRoute{
+		{Method: "GET", Path: "/both"},
+		{Method: "GET", Path: "/both", Color: "canary"},
+		{Method: "POST", Path: "/canary", Color: "canary"},
+	}
+	if len(doc.Routes) != len(want) {
+		t.Fatalf("routes: %d", len(doc.Routes))
+	}
+	for i, r := range doc.Routes {
+		if r.Method != want[i].Method || r.Path != want[i].Path || r.Color != want[i].Color {
+			t.Errorf("route %d: %+v", i, r)
+		}
+	}
+	if doc.Routes[2].Meta[MetaOwner] != "alice" {
+		t.Errorf("meta: %v", doc.Routes[2].Meta)
+	}
+}
@@ -1,6 +1,7 @@
 package pudding
 
 import (
+	"github.com/bdjimmy/pudding/metadata"
 	"github.com/pkg/errors"
 	"log"
 	"net/http"
@@ -9,22 +10,27 @@ import (
 	"time"
 )
 
+// 请求头使用规范的大小写, http.Header.Get不需要转换, 避免每个请求分配内存
 const (
 	// 记录上游的调用方
-	_httpHeaderUser = "x-pudding-user"
-	// @todo
-	_httpHeaderColor = "x-pudding-color"
+	_httpHeaderUser = "X-Pudding-User"
+	// 染色标记, 例如环境或者灰度泳道
+	_httpHeaderColor = "X-Pudding-Color"
 	// 调用方设置的超时时间
-	_httpHeaderTimeout = "x-pudding-timeout"
+	_httpHeaderTimeout = "X-Pudding-Timeout"
 	// 调用方Ip
-	_httpHeaderRemoteIP = "x-pudding-real-ip"
+	_httpHeaderRemoteIP = "X-Pudding-Real-Ip"
 	// 调用方端口
-	_httpHeaderRemoteIPPort = "x-pudding-real-port"
+	_httpHeaderRemoteIPPort = "X-Pudding-Real-Port"
+	// 压测/镜像流量标记
+	_httpHeaderMirror = "X-Pudding-Mirror"
+	// 响应中的业务码, 和render.JSON中的code一致
+	_httpHeaderStatusCode = "X-Pudding-Status-Code"
 )
 
 // 判断是否是监控请求
 func mirror(req *http.Request) bool {
-	mirrorStr := req.Header.Get("x-pudding-mirror")
+	mirrorStr := req.Header.Get(_httpHeaderMirror)
 	if mirrorStr == "" {
 		return false
 	}
@@ -39,9 +45,36 @@ func mirror(req *http.Request) bool {
 	return val
 }
 
+// 获取请求的染色标记
+func color(req *http.Request) string {
+	return strings.TrimSpace(req.Header.Get(_httpHeaderColor))
+}
+
+// newMD 从请求头构建metadata, 参数是*http.Request
+func newMD(arg interface{}) metadata.MD {
+	req := arg.(*http.Request)
+	return metadata.MD{
+		metadata.RemoteIP:   remoteIP(req),
+		metadata.RemotePort: remotePort(req),
+		metadata.Caller:     caller(req),
+		metadata.Mirror:     mirror(req),
+		metadata.Color:      color(req),
+	}
+}
+
 // 设置调用方ID
-func setCaller(req *http.Request) {
-	req.Header.Set(_httpHeaderUser, "env.AppID")
+func setCaller(req *http.Request, caller string) {
+	req.Header.Set(_httpHeaderUser, caller)
+}
+
+// 设置请求的染色标记
+func setColor(req *http.Request, color string) {
+	req.Header.Set(_httpHeaderColor, color)
+}
+
+// 设置镜像流量标记
+func setMirror(req *http.Request, mirror bool) {
+	req.Header.Set(_httpHeaderMirror, strconv.FormatBool(mirror))
 }
 
 // 获取调用方
@@ -58,6 +91,9 @@ func setTimeout(req *http.Request, timeout time.Duration) {
 // 获取客户端请求的超时时间，每次减少20ms
 func timeout(req *http.Request) time.Duration {
 	to := req.Header.Get(_httpHeaderTimeout)
+	if to == "" {
+		return 0
+	}
 	timeout, err := strconv.ParseInt(to, 10, 64)
 	if err == nil && timeout > 20 {
 		timeout -= 20
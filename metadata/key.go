@@ -9,11 +9,19 @@ const (
 	RemotePort = "remote_port"
 
 	// Trace
-	Caller = "caller"
+	Caller  = "caller"
+	TraceID = "trace_id"
+	SpanID  = "span_id"
+
+	// Color 染色标记, 用于环境隔离和灰度
+	Color = "color"
 
 	// Timeout
 	Timeout = "timeout"
 
 	// Mirror
 	Mirror = "mirror"
+
+	// Identity 鉴权中间件校验后的调用方身份, 同时会覆盖Caller
+	Identity = "identity"
 )
\ No newline at end of file
@@ -0,0 +1,50 @@
+package metadata
+
+import (
+	"context"
+	"sync"
+)
+
+// Lazy is a context.Context which builds its MD on the first lookup by FromContext, String etc.
+// The server embeds it in the pooled request context, so the requests never reading
+// the metadata don't pay for building it
+// 第一次读取metadata时才构建MD, 没有用到metadata的请求不需要构建
+type Lazy struct {
+	context.Context
+
+	mu    sync.Mutex
+	built bool
+	md    MD
+	build func(arg interface{}) MD
+	arg   interface{}
+}
+
+// Reset resets the parent context and the function building the MD from arg,
+// build should be a plain function rather than a closure to avoid the allocation
+func (l *Lazy) Reset(parent context.Context, build func(arg interface{}) MD, arg interface{}) {
+	l.Context = parent
+	l.built = false
+	l.md = nil
+	l.build = build
+	l.arg = arg
+}
+
+// Value returns the lazily built MD for the metadata key, other keys are looked up in the parent
+func (l *Lazy) Value(key interface{}) interface{} {
+	if _, ok := key.(mdKey); !ok {
+		return l.Context.Value(key)
+	}
+	l.mu.Lock()
+	if !l.built {
+		l.built = true
+		if l.build != nil {
+			l.md = l.build(l.arg)
+		}
+	}
+	md := l.md
+	l.mu.Unlock()
+	if md == nil {
+		return l.Context.Value(key)
+	}
+	return md
+}
@@ -0,0 +1,233 @@
+package pudding
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// 以 /* 结尾的路径是group前缀的配置, 例如 /api/* 对 /api 下的所有路由生效
+	_prefixWildcard = "*"
+)
+
+// prefixConfig group前缀的配置
+type prefixConfig struct {
+	prefix string
+	mc     *MethodConfig
+}
+
+// MethodConfigWatcher is called when the effective config of a path changes,
+// old is nil if the config is added and mc is nil if it's removed
+// 配置变化的回调, 新增时old为空, 删除时mc为空
+type MethodConfigWatcher func(path string, old, mc *MethodConfig)
+
+// OnMethodConfigChange registers watchers called after the method configs change
+func (engine *Engine) OnMethodConfigChange(watchers ...MethodConfigWatcher) {
+	engine.pcLock.Lock()
+	engine.configWatchers = append(engine.configWatchers, watchers...)
+	engine.pcLock.Unlock()
+}
+
+// SetMethodConfig is used to set config on specified path, the path is a route template like /user/:id,
+// or a group prefix ending with /* like /api/*, the config loaded by ReloadMethodConfigs on the same path takes precedence.
+// A nil mc removes the config of the path, the route falls back to the group prefixes
+func (engine *Engine) SetMethodConfig(path string, mc *MethodConfig) {
+	engine.pcLock.Lock()
+	// 和ReloadMethodConfigs一致, 空的配置不保存, 否则会覆盖group前缀的配置
+	if mc == nil {
+		delete(engine.staticConfigs, path)
+	} else {
+		engine.staticConfigs[path] = mc
+	}
+	changes := engine.mergeMethodConfigs()
+	watchers := engine.configWatchers
+	engine.pcLock.Unlock()
+	notifyMethodConfigs(watchers, changes)
+}
+
+// ReloadMethodConfigs replaces the configs loaded at runtime without restarting,
+// the paths missing in configs fall back to the configs set by SetMethodConfig
+// 运行时替换加载的配置, 不在configs中的路径使用代码中设置的配置
+func (engine *Engine) ReloadMethodConfigs(configs map[string]*MethodConfig) {
+	loaded := make(map[string]*MethodConfig, len(configs))
+	for path, mc := range configs {
+		if mc != nil {
+			loaded[path] = mc
+		}
+	}
+	engine.pcLock.Lock()
+	engine.loadedConfigs = loaded
+	changes := engine.mergeMethodConfigs()
+	watchers := engine.configWatchers
+	engine.pcLock.Unlock()
+	notifyMethodConfigs(watchers, changes)
+}
+
+// LoadMethodConfigs decodes the configs from JSON and reloads them, see MethodConfig for the format
+func (engine *Engine) LoadMethodConfigs(r io.Reader) error {
+	configs := make(map[string]*MethodConfig)
+	if err := json.NewDecoder(r).Decode(&configs); err != nil {
+		return errors.Wrap(err, "pudding: decode method configs")
+	}
+	engine.ReloadMethodConfigs(configs)
+	return nil
+}
+
+// LoadMethodConfigFile loads the method configs from the JSON file
+func (engine *Engine) LoadMethodConfigFile(file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return errors.Wrap(err, "pudding: open method config file")
+	}
+	defer f.Close()
+	return errors.WithMessage(engine.LoadMethodConfigs(f), file)
+}
+
+// WatchMethodConfigFile loads the method configs from the JSON file, then checks the file
+// every interval and reloads it if the modification time changes, until ctx is done.
+// The error of the first loading is returned, the later errors are logged and the old configs are kept
+// 先加载一次配置文件, 之后定时检查修改时间并重新加载, 加载失败时保留原来的配置
+func (engine *Engine) WatchMethodConfigFile(ctx context.Context, file string, interval time.Duration) error {
+	fi, err := os.Stat(file)
+	if err != nil {
+		return errors.Wrap(err, "pudding: stat method config file")
+	}
+	if err = engine.LoadMethodConfigFile(file); err != nil {
+		return err
+	}
+	go func() {
+		modTime := fi.ModTime()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			fi, err := os.Stat(file)
+			if err != nil {
+				log.Printf("pudding: watch method config file error(%v)", err)
+				continue
+			}
+			if fi.ModTime().Equal(modTime) {
+				continue
+			}
+			modTime = fi.ModTime()
+			if err = engine.LoadMethodConfigFile(file); err != nil {
+				log.Printf("pudding: reload method config file error(%+v)", err)
+				continue
+			}
+			log.Printf("pudding: method config file %s reloaded", file)
+		}
+	}()
+	return nil
+}
+
+// methodConfigChange 一个路径的配置变化
+type methodConfigChange struct {
+	path    string
+	old, mc *MethodConfig
+}
+
+// mergeMethodConfigs 合并代码中设置的和运行时加载的配置, 返回变化的路径, 调用者需要持有pcLock
+func (engine *Engine) mergeMethodConfigs() (changes []methodConfigChange) {
+	merged := make(map[string]*MethodConfig, len(engine.staticConfigs)+len(engine.loadedConfigs))
+	for path, mc := range engine.staticConfigs {
+		merged[path] = mc
+	}
+	for path, mc := range engine.loadedConfigs {
+		merged[path] = mc
+	}
+	for path, mc := range merged {
+		old, ok := engine.methodConfigs[path]
+		if ok && reflect.DeepEqual(old, mc) {
+			// 没有变化时保留原来的配置, 路由的限流和并发状态不会被重置
+			merged[path] = old
+			continue
+		}
+		changes = append(changes, methodConfigChange{path: path, old: old, mc: mc})
+	}
+	for path, old := range engine.methodConfigs {
+		if _, ok := merged[path]; !ok {
+			changes = append(changes, methodConfigChange{path: path, old: old})
+		}
+	}
+	// 替换整个map, 读取时不需要复制
+	engine.methodConfigs = merged
+	// 前缀按长度倒序, 最长的前缀优先
+	prefixes := make([]prefixConfig, 0)
+	for path, mc := range merged {
+		if strings.HasSuffix(path, "/"+_prefixWildcard) {
+			prefixes = append(prefixes, prefixConfig{prefix: strings.TrimSuffix(path, _prefixWildcard), mc: mc})
+		}
+	}
+	sort.Slice(prefixes, func(i, j int) bool {
+		return len(prefixes[i].prefix) > len(prefixes[j].prefix)
+	})
+	engine.prefixConfigs = prefixes
+	return
+}
+
+// methodConfig 返回路由模板的配置: 先精确匹配路由, 再匹配最长的group前缀, 未匹配到路由时返回空
+func (engine *Engine) methodConfig(route string) *MethodConfig {
+	if route == "" {
+		return nil
+	}
+	engine.pcLock.RLock()
+	defer engine.pcLock.RUnlock()
+	if mc, ok := engine.methodConfigs[route]; ok {
+		return mc
+	}
+	for _, pc := range engine.prefixConfigs {
+		// 前缀 /api/ 匹配 /api 和 /api/ 下的所有路由
+		if strings.HasPrefix(route, pc.prefix) || route == pc.prefix[:len(pc.prefix)-1] {
+			return pc.mc
+		}
+	}
+	return nil
+}
+
+// effectiveConfig 把路由的配置和ServerConfig中的默认值合并到dst中
+func (engine *Engine) effectiveConfig(dst *MethodConfig, mc *MethodConfig) {
+	if mc != nil {
+		*dst = *mc
+	} else {
+		*dst = MethodConfig{}
+	}
+	engine.lock.RLock()
+	conf := engine.conf
+	engine.lock.RUnlock()
+	if dst.Timeout <= 0 {
+		dst.Timeout = conf.TimeOut
+	}
+	if dst.MaxBodyBytes <= 0 {
+		dst.MaxBodyBytes = conf.MaxBodyBytes
+	}
+	if dst.MaxMultipartMemory <= 0 {
+		dst.MaxMultipartMemory = conf.MaxMultipartMemory
+	}
+	if dst.MaxMultipartMemory <= 0 {
+		dst.MaxMultipartMemory = defaultMaxMemory
+	}
+	if dst.MaxFiles <= 0 {
+		dst.MaxFiles = conf.MaxFiles
+	}
+}
+
+func notifyMethodConfigs(watchers []MethodConfigWatcher, changes []methodConfigChange) {
+	for _, change := range changes {
+		for _, w := range watchers {
+			w(change.path, change.old, change.mc)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package pudding
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bdjimmy/pudding/utils"
+)
+
+func TestMethodConfigResolution(t *testing.T) {
+	engine := New()
+	configs := map[string]*MethodConfig{
+		"/*":             {Timeout: utils.Duration(1 * time.Second)},
+		"/api/*":         {Timeout: utils.Duration(2 * time.Second)},
+		"/api/user/*":    {Timeout: utils.Duration(3 * time.Second)},
+		"/api/user/:id":  {Timeout: utils.Duration(4 * time.Second)},
+		"/api":           {Timeout: utils.Duration(5 * time.Second)},
+		"/static/*file":  {Timeout: utils.Duration(6 * time.Second)},
+		"/internal/v1/*": {Timeout: utils.Duration(7 * time.Second)},
+	}
+	for path, mc := range configs {
+		engine.SetMethodConfig(path, mc)
+	}
+	cases := []struct {
+		route   string
+		timeout time.Duration
+	}{
+		// 路由的精确配置优先于所有的前缀, 即使前缀更长
+		{"/api/user/:id", 4 * time.Second},
+		{"/api", 5 * time.Second},
+		// 最长的前缀优先
+		{"/api/user/list", 3 * time.Second},
+		{"/api/user/:id/orders", 3 * time.Second},
+		{"/api/order", 2 * time.Second},
+		// /api/user/* 对 /api/user 生效
+		{"/api/user", 3 * time.Second},
+		{"/api/", 2 * time.Second},
+		// 前缀按路径段匹配, /api/* 不匹配 /apix
+		{"/apix", 1 * time.Second},
+		{"/api2/user", 1 * time.Second},
+		// 通配路由的模板按精确路由匹配
+		{"/static/*file", 6 * time.Second},
+		{"/internal/v1", 7 * time.Second},
+		{"/internal/v2", 1 * time.Second},
+		{"/internal", 1 * time.Second},
+	}
+	for _, cs := range cases {
+		mc := engine.methodConfig(cs.route)
+		if mc == nil || time.Duration(mc.Timeout) != cs.timeout {
+			t.Errorf("%s: %+v, want timeout %v", cs.route, mc, cs.timeout)
+		}
+	}
+	// 未匹配到路由时没有配置
+	if mc := engine.methodConfig(""); mc != nil {
+		t.Errorf("unmatched: %+v", mc)
+	}
+
+	// /api 的精确配置删除后, /api 使用 /api/* 的配置
+	engine.SetMethodConfig("/api", nil)
+	if mc := engine.methodConfig("/api"); mc == nil || time.Duration(mc.Timeout) != 2*time.Second {
+		t.Errorf("/api without exact config: %+v", mc)
+	}
+	// 运行时加载的配置覆盖同一路径上代码中的配置, 不影响其他路径
+	engine.ReloadMethodConfigs(map[string]*MethodConfig{"/api/*": {Timeout: utils.Duration(8 * time.Second)}})
+	for route, timeout := range map[string]time.Duration{"/api": 8 * time.Second, "/api/order": 8 * time.Second, "/api/user/list": 3 * time.Second} {
+		if mc := engine.methodConfig(route); mc == nil || time.Duration(mc.Timeout) != timeout {
+			t.Errorf("reloaded %s: %+v, want timeout %v", route, mc, timeout)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package pudding
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bdjimmy/pudding/metrics"
+)
+
+const (
+	// 未匹配到路由的请求使用的path标签, 避免原始url导致标签基数无限增长
+	_metricUnmatchedPath = "unmatched"
+	// 非标准的http方法使用的method标签
+	_metricOtherMethod = "other"
+)
+
+var (
+	_metricServerReqCount = metrics.NewCounterVec(&metrics.Opts{
+		Namespace: "http_server",
+		Subsystem: "requests",
+		Name:      "total",
+		Help:      "http server requests count by method, route and status.",
+		Labels:    []string{"method", "path", "status"},
+	})
+	_metricServerReqDur = metrics.NewHistogramVec(&metrics.HistogramOpts{
+		Opts: metrics.Opts{
+			Namespace: "http_server",
+			Subsystem: "requests",
+			Name:      "duration_seconds",
+			Help:      "http server requests duration(s) by method and route.",
+			Labels:    []string{"method", "path"},
+		},
+		Buckets: []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+	})
+	_metricServerInFlight = metrics.NewGaugeVec(&metrics.Opts{
+		Namespace: "http_server",
+		Subsystem: "requests",
+		Name:      "in_flight",
+		Help:      "http server requests in flight by method and route.",
+		Labels:    []string{"method", "path"},
+	})
+	_metricServerCode = metrics.NewCounterVec(&metrics.Opts{
+		Namespace: "http_server",
+		Subsystem: "requests",
+		Name:      "code_total",
+		Help:      "http server requests count by method, route and business code.",
+		Labels:    []string{"method", "path", "code"},
+	})
+)
+
+// Metrics registers the handler exposing the metrics in Prometheus text format on path, e.g. /metrics
+// 在指定路由上暴露Prometheus格式的监控数据
+func (engine *Engine) Metrics(path string) {
+	engine.GET(path, WrapH(metrics.Handler()))
+}
+
+// metricMethod 非标准的方法使用固定的标签, 避免任意方法导致标签基数无限增长
+func metricMethod(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	}
+	return _metricOtherMethod
+}
+
+// metricsBegin 记录开始处理的请求, 返回值传给metricsEnd, 不使用闭包避免每个请求分配内存
+func metricsBegin(c *Context) (method, path string, start time.Time) {
+	method = metricMethod(c.Request.Method)
+	path = c.RoutePath
+	if path == "" {
+		path = _metricUnmatchedPath
+	}
+	_metricServerInFlight.Inc(method, path)
+	return method, path, time.Now()
+}
+
+// metricsEnd 统计请求数、耗时和业务码
+func metricsEnd(c *Context, method, path string, start time.Time) {
+	_metricServerInFlight.Dec(method, path)
+	_metricServerReqDur.Observe(time.Since(start).Seconds(), method, path)
+	status := http.StatusOK
+	if rw, ok := c.Writer.(ResponseWriter); ok {
+		status = rw.Status()
+	}
+	_metricServerReqCount.Inc(method, path, statusText(status))
+	// 业务码来自render.JSON
+	if code := c.Writer.Header().Get(_httpHeaderStatusCode); code != "" {
+		_metricServerCode.Inc(method, path, code)
+	}
+}
+
+// _statusTexts 预先格式化的HTTP状态码
+var _statusTexts = func() (texts [600]string) {
+	for i := range texts {
+		texts[i] = strconv.Itoa(i)
+	}
+	return
+}()
+
+// statusText 状态码的字符串, 常见的状态码不分配内存
+func statusText(status int) string {
+	if status >= 0 && status < len(_statusTexts) {
+		return _statusTexts[status]
+	}
+	return strconv.Itoa(status)
+}
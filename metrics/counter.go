@@ -0,0 +1,61 @@
+package metrics
+
+import (
+	"io"
+)
+
+// CounterVec is a counter partitioned by label values
+type CounterVec struct {
+	*vec
+}
+
+// NewCounterVec new a counter vector and registers it into the DefaultRegistry
+func NewCounterVec(o *Opts) *CounterVec {
+	c := &CounterVec{vec: newVec(o, TypeCounter)}
+	DefaultRegistry.MustRegister(c)
+	return c
+}
+
+// Inc increments the counter by 1
+func (c *CounterVec) Inc(labels ...string) {
+	c.Add(1, labels...)
+}
+
+// Add adds v to the counter, v must not be negative
+func (c *CounterVec) Add(v float64, labels ...string) {
+	if v < 0 {
+		return
+	}
+	s := c.with(0, labels...)
+	s.mu.Lock()
+	s.value += v
+	s.mu.Unlock()
+}
+
+// Value returns the current value of the counter, 0 if the labels have no series,
+// reading doesn't create the series
+func (c *CounterVec) Value(labels ...string) float64 {
+	s := c.lookup(labels...)
+	if s == nil {
+		return 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.value
+}
+
+// Write implements Collector
+func (c *CounterVec) Write(w io.Writer) error {
+	if err := c.writeHeader(w); err != nil {
+		return err
+	}
+	for _, s := range c.sorted() {
+		s.mu.Lock()
+		v := s.value
+		s.mu.Unlock()
+		if err := writeSample(w, c.name, c.labels, s.values, "", "", v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestValueDoesNotCreateSeries(t *testing.T) {
+	c := NewCounterVec(&Opts{Name: "test_value_counter", Labels: []string{"code"}})
+	defer DefaultRegistry.Unregister(c.Name())
+	g := NewGaugeVec(&Opts{Name: "test_value_gauge", Labels: []string{"code"}})
+	defer DefaultRegistry.Unregister(g.Name())
+	if c.Value("200") != 0 || g.Value("200") != 0 {
+		t.Fatal("missing series has a value")
+	}
+	var buf bytes.Buffer
+	if err := c.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), `code="200"`) {
+		t.Fatalf("reading created the series:\n%s", buf.String())
+	}
+	c.Inc("200")
+	g.Set(3, "200")
+	if c.Value("200") != 1 || g.Value("200") != 3 {
+		t.Fatalf("counter(%v) gauge(%v)", c.Value("200"), g.Value("200"))
+	}
+}
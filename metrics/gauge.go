@@ -0,0 +1,71 @@
+package metrics
+
+import (
+	"io"
+)
+
+// GaugeVec is a gauge partitioned by label values
+type GaugeVec struct {
+	*vec
+}
+
+// NewGaugeVec new a gauge vector and registers it into the DefaultRegistry
+func NewGaugeVec(o *Opts) *GaugeVec {
+	g := &GaugeVec{vec: newVec(o, TypeGauge)}
+	DefaultRegistry.MustRegister(g)
+	return g
+}
+
+// Set sets the gauge to v
+func (g *GaugeVec) Set(v float64, labels ...string) {
+	s := g.with(0, labels...)
+	s.mu.Lock()
+	s.value = v
+	s.mu.Unlock()
+}
+
+// Inc increments the gauge by 1
+func (g *GaugeVec) Inc(labels ...string) {
+	g.Add(1, labels...)
+}
+
+// Dec decrements the gauge by 1
+func (g *GaugeVec) Dec(labels ...string) {
+	g.Add(-1, labels...)
+}
+
+// Add adds v to the gauge, v can be negative
+func (g *GaugeVec) Add(v float64, labels ...string) {
+	s := g.with(0, labels...)
+	s.mu.Lock()
+	s.value += v
+	s.mu.Unlock()
+}
+
+// Value returns the current value of the gauge, 0 if the labels have no series,
+// reading doesn't create the series
+func (g *GaugeVec) Value(labels ...string) float64 {
+	s := g.lookup(labels...)
+	if s == nil {
+		return 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.value
+}
+
+// Write implements Collector
+func (g *GaugeVec) Write(w io.Writer) error {
+	if err := g.writeHeader(w); err != nil {
+		return err
+	}
+	for _, s := range g.sorted() {
+		s.mu.Lock()
+		v := s.value
+		s.mu.Unlock()
+		if err := writeSample(w, g.name, g.labels, s.values, "", "", v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package metrics
+
+import (
+	"io"
+	"math"
+	"sort"
+)
+
+// DefBuckets are the default histogram buckets in seconds
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// HistogramOpts is the options of the histogram vector
+type HistogramOpts struct {
+	Opts
+	// Buckets 升序排列的桶上界, 不需要包含+Inf
+	Buckets []float64
+}
+
+// HistogramVec is a histogram partitioned by label values
+type HistogramVec struct {
+	*vec
+	buckets []float64
+}
+
+// NewHistogramVec new a histogram vector and registers it into the DefaultRegistry
+func NewHistogramVec(o *HistogramOpts) *HistogramVec {
+	buckets := o.Buckets
+	if len(buckets) == 0 {
+		buckets = DefBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	if math.IsInf(buckets[len(buckets)-1], 1) {
+		buckets = buckets[:len(buckets)-1]
+	}
+	h := &HistogramVec{vec: newVec(&o.Opts, TypeHistogram), buckets: buckets}
+	DefaultRegistry.MustRegister(h)
+	return h
+}
+
+// Observe adds a single observation to the histogram
+func (h *HistogramVec) Observe(v float64, labels ...string) {
+	s := h.with(len(h.buckets), labels...)
+	i := sort.SearchFloat64s(h.buckets, v)
+	s.mu.Lock()
+	if i < len(h.buckets) {
+		s.counts[i]++
+	}
+	s.sum += v
+	s.count++
+	s.mu.Unlock()
+}
+
+// Write implements Collector
+func (h *HistogramVec) Write(w io.Writer) error {
+	if err := h.writeHeader(w); err != nil {
+		return err
+	}
+	for _, s := range h.sorted() {
+		s.mu.Lock()
+		counts := append([]uint64(nil), s.counts...)
+		sum, count := s.sum, s.count
+		s.mu.Unlock()
+		// 每个桶输出累计值
+		var cumulative uint64
+		for i, upper := range h.buckets {
+			cumulative += counts[i]
+			if err := writeSample(w, h.name+"_bucket", h.labels, s.values, "le", formatFloat(upper), float64(cumulative)); err != nil {
+				return err
+			}
+		}
+		if err := writeSample(w, h.name+"_bucket", h.labels, s.values, "le", "+Inf", float64(count)); err != nil {
+			return err
+		}
+		if err := writeSample(w, h.name+"_sum", h.labels, s.values, "", "", sum); err != nil {
+			return err
+		}
+		if err := writeSample(w, h.name+"_count", h.labels, s.values, "", "", float64(count)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
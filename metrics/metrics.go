@@ -0,0 +1,218 @@
+// Package metrics is a minimal metrics library which exposes counters, gauges and histograms
+// in the Prometheus text exposition format without any external dependency
+package metrics
+
+import (
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// metric types
+const (
+	TypeCounter   = "counter"
+	TypeGauge     = "gauge"
+	TypeHistogram = "histogram"
+)
+
+// 拼接label值的分隔符, 不会出现在合法的utf8字符串中
+const _labelSep = "\xff"
+
+// Opts is the options of the vectors
+type Opts struct {
+	Namespace string
+	Subsystem string
+	Name      string
+	Help      string
+	Labels    []string
+}
+
+// fullName 拼接 namespace_subsystem_name
+func (o *Opts) fullName() string {
+	parts := make([]string, 0, 3)
+	for _, p := range []string{o.Namespace, o.Subsystem, o.Name} {
+		if p != "" {
+			parts = append(parts, p)
+		}
+	}
+	return strings.Join(parts, "_")
+}
+
+// Collector is a metric which can be exposed by the Registry
+type Collector interface {
+	// Name returns the full name of the metric
+	Name() string
+	// Write writes the metric in the text exposition format
+	Write(w io.Writer) error
+}
+
+// vec 按label值保存序列
+type vec struct {
+	name   string
+	help   string
+	typ    string
+	labels []string
+
+	mu     sync.RWMutex
+	series map[string]*series
+}
+
+type series struct {
+	values []string
+	mu     sync.Mutex
+	value  float64
+	// histogram
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func newVec(o *Opts, typ string) *vec {
+	if o.Name == "" {
+		panic("metrics: metric name must not be empty")
+	}
+	return &vec{
+		name:   o.fullName(),
+		help:   o.Help,
+		typ:    typ,
+		labels: o.Labels,
+		series: make(map[string]*series),
+	}
+}
+
+func (v *vec) Name() string {
+	return v.name
+}
+
+// with 获取label值对应的序列, 不存在时创建
+func (v *vec) with(buckets int, values ...string) *series {
+	s := v.lookup(values...)
+	if s != nil {
+		return s
+	}
+	key := strings.Join(values, _labelSep)
+	v.mu.Lock()
+	if s = v.series[key]; s == nil {
+		s = &series{values: append([]string(nil), values...)}
+		if buckets > 0 {
+			s.counts = make([]uint64, buckets)
+		}
+		v.series[key] = s
+	}
+	v.mu.Unlock()
+	return s
+}
+
+// lookup 获取label值对应的序列, 不存在时返回nil, 不会创建序列
+func (v *vec) lookup(values ...string) *series {
+	if len(values) != len(v.labels) {
+		panic(errors.Errorf("metrics: %s expected %d label values but got %d", v.name, len(v.labels), len(values)))
+	}
+	var buf [128]byte
+	key := appendKey(buf[:0], values)
+	v.mu.RLock()
+	// map[string(bytes)] 不会分配内存
+	s := v.series[string(key)]
+	v.mu.RUnlock()
+	return s
+}
+
+// appendKey 用_labelSep拼接label值
+func appendKey(dst []byte, values []string) []byte {
+	for i, value := range values {
+		if i > 0 {
+			dst = append(dst, _labelSep...)
+		}
+		dst = append(dst, value...)
+	}
+	return dst
+}
+
+// sorted 按label值排序的序列, 保证输出稳定
+func (v *vec) sorted() []*series {
+	v.mu.RLock()
+	keys := make([]string, 0, len(v.series))
+	for k := range v.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	ss := make([]*series, 0, len(keys))
+	for _, k := range keys {
+		ss = append(ss, v.series[k])
+	}
+	v.mu.RUnlock()
+	return ss
+}
+
+func (v *vec) writeHeader(w io.Writer) error {
+	if v.help != "" {
+		if _, err := io.WriteString(w, "# HELP "+v.name+" "+escapeHelp(v.help)+"\n"); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, "# TYPE "+v.name+" "+v.typ+"\n")
+	return err
+}
+
+// writeSample 输出一行 name{labels} value
+func writeSample(w io.Writer, name string, labels, values []string, extraName, extraValue string, value float64) error {
+	var b strings.Builder
+	b.WriteString(name)
+	if len(labels) > 0 || extraName != "" {
+		b.WriteByte('{')
+		for i, l := range labels {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(l)
+			b.WriteString(`="`)
+			b.WriteString(escapeLabel(values[i]))
+			b.WriteByte('"')
+		}
+		if extraName != "" {
+			if len(labels) > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(extraName)
+			b.WriteString(`="`)
+			b.WriteString(extraValue)
+			b.WriteByte('"')
+		}
+		b.WriteByte('}')
+	}
+	b.WriteByte(' ')
+	b.WriteString(formatFloat(value))
+	b.WriteByte('\n')
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	case math.IsNaN(f):
+		return "NaN"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+var (
+	_labelReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+	_helpReplacer  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(s string) string {
+	return _labelReplacer.Replace(s)
+}
+
+func escapeHelp(s string) string {
+	return _helpReplacer.Replace(s)
+}
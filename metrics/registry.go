@@ -0,0 +1,92 @@
+package metrics
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// ContentType is the content type of the text exposition format
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultRegistry is the registry used by the New* functions
+var DefaultRegistry = NewRegistry()
+
+// Registry holds the collectors and exposes them
+type Registry struct {
+	mu         sync.RWMutex
+	collectors map[string]Collector
+}
+
+// NewRegistry new a empty registry
+func NewRegistry() *Registry {
+	return &Registry{collectors: make(map[string]Collector)}
+}
+
+// Register registers the collector, the name must be unique
+func (r *Registry) Register(c Collector) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.collectors[c.Name()]; ok {
+		return errors.Errorf("metrics: duplicate metric %s", c.Name())
+	}
+	r.collectors[c.Name()] = c
+	return nil
+}
+
+// MustRegister registers the collector and panics on error
+func (r *Registry) MustRegister(c Collector) {
+	if err := r.Register(c); err != nil {
+		panic(err)
+	}
+}
+
+// Unregister removes the collector by name
+func (r *Registry) Unregister(name string) {
+	r.mu.Lock()
+	delete(r.collectors, name)
+	r.mu.Unlock()
+}
+
+// WriteText writes all the metrics sorted by name in the text exposition format
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.RLock()
+	names := make([]string, 0, len(r.collectors))
+	for name := range r.collectors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	cs := make([]Collector, 0, len(names))
+	for _, name := range names {
+		cs = append(cs, r.collectors[name])
+	}
+	r.mu.RUnlock()
+	for _, c := range cs {
+		if err := c.Write(w); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+// Handler returns the http handler exposing the registry
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		buf := &bytes.Buffer{}
+		if err := r.WriteText(buf); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", ContentType)
+		w.Write(buf.Bytes())
+	})
+}
+
+// Handler returns the http handler exposing the DefaultRegistry
+func Handler() http.Handler {
+	return DefaultRegistry.Handler()
+}
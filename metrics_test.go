@@ -0,0 +1,33 @@
+package pudding
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMetricMethodBounded(t *testing.T) {
+	engine := New()
+	engine.GET("/m", func(c *Context) {})
+	// 计数器是进程全局的, 只校验本次请求的增量, 支持 go test -count=N
+	before := _metricServerReqCount.Value(_metricOtherMethod, _metricUnmatchedPath, "404")
+	for _, method := range []string{"FOO1", "FOO2"} {
+		engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, "/missing", nil))
+	}
+	if v := _metricServerReqCount.Value(_metricOtherMethod, _metricUnmatchedPath, "404") - before; v != 2 {
+		t.Fatalf("other method count: %v", v)
+	}
+	if v := _metricServerReqCount.Value("FOO1", _metricUnmatchedPath, "404"); v != 0 {
+		t.Fatalf("raw method label: %v", v)
+	}
+	for _, cs := range []struct{ method, want string }{
+		{http.MethodGet, http.MethodGet},
+		{http.MethodPatch, http.MethodPatch},
+		{"PROPFIND", _metricOtherMethod},
+		{"", _metricOtherMethod},
+	} {
+		if got := metricMethod(cs.method); got != cs.want {
+			t.Errorf("metricMethod(%q) = %s, want %s", cs.method, got, cs.want)
+		}
+	}
+}
@@ -2,40 +2,217 @@
 package perf
 
 import (
-	"sync"
+	"flag"
+	"fmt"
+	"log"
+	"net"
 	"net/http"
 	"net/http/pprof"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+
 	"github.com/pkg/errors"
 )
 
+const (
+	// 独立监听时pprof的路径前缀
+	_pathPrefix = "/debug/pprof/"
+	// 关闭独立监听的pprof
+	_addrOff = "off"
+	// 默认的独立监听地址, 只允许本机访问
+	_defaultAddr = "127.0.0.1:2333"
+)
+
 var (
-	_perfOnce sync.Once
+	// 独立监听的地址和listener, 为空时没有启动
+	_perfLock     sync.Mutex
+	_perfAddr     string
+	_perfListener net.Listener
+
+	// 通过 -http.perf 参数或者 HTTP_PERF 环境变量设置的监听地址, off 表示关闭
+	_flagAddr string
+
+	// 当前的block和mutex采样率
+	_rateLock      sync.Mutex
+	_blockRate     int
+	_mutexFraction int
 )
 
-func StartPerf(){
-	_perfOnce.Do(func() {
-		mux := http.NewServeMux()
-		mux.HandleFunc("/debug/pprof/", pprof.Index)
-		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
-		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
-		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
-
-		go func() {
-			if err := http.ListenAndServe("0.0.0.0:2333", mux); err != nil{
-				panic(errors.Errorf("pudding: listen %s: error(%v)", "0.0.0.0:2333", err))
-			}
-		}()
-	})
+func init() {
+	addFlag(flag.CommandLine)
 }
 
+func addFlag(fs *flag.FlagSet) {
+	v := os.Getenv("HTTP_PERF")
+	if v == "" {
+		// 默认只监听本机, 对外暴露时需要显式配置
+		v = _defaultAddr
+	}
+	fs.StringVar(&_flagAddr, "http.perf", v, "listen http perf address, off to disable, or use HTTP_PERF env variable.")
+}
 
+// Config is the pprof config
+type Config struct {
+	// Enabled 是否独立监听pprof端口
+	Enabled bool
+	// Address 独立监听的地址, 例如 127.0.0.1:2333, 独立监听的端口没有鉴权, 不要对外暴露
+	Address string
+	// BlockProfileRate 参见 runtime.SetBlockProfileRate, 0 不修改
+	BlockProfileRate int
+	// MutexProfileFraction 参见 runtime.SetMutexProfileFraction, 0 不修改
+	MutexProfileFraction int
+}
 
+// DefaultConfig returns the config from -http.perf flag or HTTP_PERF env
+func DefaultConfig() *Config {
+	addr := strings.TrimSpace(_flagAddr)
+	return &Config{
+		Enabled: addr != "" && addr != _addrOff,
+		Address: addr,
+	}
+}
 
+// Start starts a standalone pprof server by the config, it is only started once in a process.
+// The standalone server has no authentication, so it serves the read-only profiles without rate.
+// The listen error is returned instead of panicking and the next Start tries again,
+// starting on another address while it's running returns an error
+// 一个进程只会启动一次, 端口冲突等错误会返回而不是panic, 下次调用会重试
+func Start(c *Config) error {
+	if c == nil {
+		c = DefaultConfig()
+	}
+	SetRates(c.BlockProfileRate, c.MutexProfileFraction)
+	if !c.Enabled {
+		return nil
+	}
+	_perfLock.Lock()
+	defer _perfLock.Unlock()
+	if _perfAddr != "" {
+		if _perfAddr == c.Address {
+			return nil
+		}
+		return errors.Errorf("pudding: perf is already listening on %s, ignore %s", _perfAddr, c.Address)
+	}
+	l, err := net.Listen("tcp", c.Address)
+	if err != nil {
+		return errors.Wrapf(err, "pudding: perf listen %s", c.Address)
+	}
+	_perfAddr, _perfListener = c.Address, l
+	log.Printf("pudding: start perf listen addr: %s", l.Addr())
+	mux := http.NewServeMux()
+	mux.Handle(_pathPrefix, Handler(_pathPrefix))
+	go func() {
+		if err := http.Serve(l, mux); err != nil {
+			log.Printf("pudding: perf serve %s: error(%v)", c.Address, err)
+		}
+	}()
+	return nil
+}
 
+// StartPerf starts the standalone pprof server by DefaultConfig
+func StartPerf() {
+	if err := Start(DefaultConfig()); err != nil {
+		log.Printf("%+v", err)
+	}
+}
 
+// Handler returns the read-only pprof handler serving under the path prefix, e.g. /debug/pprof/,
+// 包括 index, cmdline, profile, symbol, trace, heap, goroutine, allocs, block, mutex, threadcreate
+func Handler(prefix string) http.Handler {
+	return handler(prefix, false)
+}
 
+// AdminHandler returns the pprof handler with rate changing the block and mutex profile rates,
+// it must be protected by the authentication, RouterGroup.Perf mounts it behind the group middleware
+// 包括修改block和mutex采样率的 rate, 需要有鉴权保护
+func AdminHandler(prefix string) http.Handler {
+	return handler(prefix, true)
+}
 
+func handler(prefix string, admin bool) http.Handler {
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.Trim(strings.TrimPrefix(r.URL.Path, prefix), "/")
+		switch name {
+		case "":
+			pprof.Index(w, r)
+		case "cmdline":
+			pprof.Cmdline(w, r)
+		case "profile":
+			pprof.Profile(w, r)
+		case "symbol":
+			pprof.Symbol(w, r)
+		case "trace":
+			pprof.Trace(w, r)
+		case "rate":
+			if !admin {
+				http.NotFound(w, r)
+				return
+			}
+			rate(w, r)
+		default:
+			// heap, goroutine, allocs, block, mutex, threadcreate ...
+			pprof.Handler(name).ServeHTTP(w, r)
+		}
+	})
+}
 
+// SetRates sets the block profile rate and the mutex profile fraction at runtime,
+// a negative value turns the profile off and 0 leaves it unchanged
+// 运行时修改采样率, 负数关闭, 0 不修改
+func SetRates(block, mutex int) {
+	_rateLock.Lock()
+	defer _rateLock.Unlock()
+	if block != 0 {
+		if block < 0 {
+			block = 0
+		}
+		runtime.SetBlockProfileRate(block)
+		_blockRate = block
+	}
+	if mutex != 0 {
+		if mutex < 0 {
+			mutex = 0
+		}
+		runtime.SetMutexProfileFraction(mutex)
+		_mutexFraction = mutex
+	}
+}
 
+// rate 查看或者修改采样率, 例如 POST rate?block=1&mutex=5
+func rate(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		block, err := parseRate(r.FormValue("block"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		mutex, err := parseRate(r.FormValue("mutex"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		SetRates(block, mutex)
+	}
+	_rateLock.Lock()
+	block, mutex := _blockRate, _mutexFraction
+	_rateLock.Unlock()
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintf(w, "block=%d\nmutex=%d\n", block, mutex)
+}
 
-
+func parseRate(v string) (int, error) {
+	if v == "" {
+		return 0, nil
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid rate %q", v)
+	}
+	return i, nil
+}
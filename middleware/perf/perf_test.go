@@ -0,0 +1,81 @@
+package perf
+
+import (
+	"flag"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDefaultAddrLoopback(t *testing.T) {
+	fs := flag.NewFlagSet("perf", flag.ContinueOnError)
+	t.Setenv("HTTP_PERF", "")
+	addFlag(fs)
+	if host, _, _ := net.SplitHostPort(_flagAddr); host != "127.0.0.1" {
+		t.Fatalf("default perf address: %s", _flagAddr)
+	}
+}
+
+func TestHandlerRate(t *testing.T) {
+	cases := []struct {
+		h    http.Handler
+		code int
+	}{
+		{Handler("/debug/pprof/"), http.StatusNotFound},
+		{AdminHandler("/debug/pprof/"), http.StatusOK},
+	}
+	for _, cs := range cases {
+		w := httptest.NewRecorder()
+		cs.h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/pprof/rate", nil))
+		if w.Code != cs.code {
+			t.Errorf("rate: %d, want %d", w.Code, cs.code)
+		}
+	}
+	w := httptest.NewRecorder()
+	AdminHandler("/debug/pprof/").ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/debug/pprof/rate?block=x", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("invalid rate: %d", w.Code)
+	}
+}
+
+func TestStartErrors(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	busy := l.Addr().String()
+	t.Cleanup(func() {
+		_perfLock.Lock()
+		if _perfListener != nil {
+			_perfListener.Close()
+		}
+		_perfAddr, _perfListener = "", nil
+		_perfLock.Unlock()
+	})
+	// 端口被占用时每次启动都返回错误
+	for i := 0; i < 2; i++ {
+		if err := Start(&Config{Enabled: true, Address: busy}); err == nil {
+			t.Fatalf("start %d on a busy address", i)
+		}
+	}
+	l.Close()
+	if err := Start(&Config{Enabled: true, Address: busy}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Start(&Config{Enabled: true, Address: busy}); err != nil {
+		t.Fatalf("restart on the same address: %v", err)
+	}
+	if err := Start(&Config{Enabled: true, Address: "127.0.0.1:0"}); err == nil || !strings.Contains(err.Error(), "already listening") {
+		t.Fatalf("start on another address: %v", err)
+	}
+	resp, err := http.Get("http://" + busy + "/debug/pprof/rate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("standalone rate: %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,107 @@
+package pudding
+
+import (
+	"net"
+
+	"github.com/bdjimmy/pudding/auth"
+	"github.com/bdjimmy/pudding/ecode"
+	"github.com/bdjimmy/pudding/metrics"
+	"github.com/bdjimmy/pudding/ratelimit"
+)
+
+// limiter names used in the metrics and the hooks
+const (
+	// LimiterQuota MethodConfig.Quota 的令牌桶
+	LimiterQuota = "quota"
+	// LimiterConcurrency MethodConfig.MaxConcurrency 的并发限制
+	LimiterConcurrency = "concurrency"
+	// LimiterCaller 按调用方限流
+	LimiterCaller = "caller"
+	// LimiterAdaptive 自适应限流
+	LimiterAdaptive = "adaptive"
+)
+
+// CallerLimit 最多记录的调用方个数, 超过时淘汰最久没有请求的调用方
+const _callerLimitSize = 10240
+
+var _metricServerLimited = metrics.NewCounterVec(&metrics.Opts{
+	Namespace: "http_server",
+	Subsystem: "requests",
+	Name:      "limited_total",
+	Help:      "http server rejected requests count by method, route and limiter.",
+	Labels:    []string{"method", "path", "limiter"},
+})
+
+// LimitHook is called when a request is rejected by the limiter, before the error is rendered
+type LimitHook func(c *Context, limiter string)
+
+// OnLimit registers the hooks called when the requests are rejected by the limiters,
+// e.g. to report the rejected callers
+func (engine *Engine) OnLimit(hooks ...LimitHook) {
+	engine.guardLock.Lock()
+	engine.limitHooks = append(engine.limitHooks, hooks...)
+	engine.guardLock.Unlock()
+}
+
+// CallerLimit limits the requests of every caller with a token bucket, quota is the requests
+// per second and burst defaults to ceil(quota). The caller is the identity verified by the auth
+// middleware, e.g. JWTAuth, so it must run after them, the requests without identity are limited
+// by the remote address of the connection. The unauthenticated x-pudding-user header is not used
+// since the clients can change it to skip the limit. At most _callerLimitSize callers are tracked
+// 按调用方限流, 调用方取鉴权后的身份, 没有身份时按连接的远端地址限流, 不使用未经校验的调用方请求头
+func CallerLimit(quota float64, burst int) HandlerFunc {
+	group := ratelimit.NewBoundedGroup(func() ratelimit.Limiter {
+		return ratelimit.NewTokenBucket(quota, burst)
+	}, _callerLimitSize)
+	return func(c *Context) {
+		if _, err := group.Allow(limitCaller(c)); err != nil {
+			c.abortLimited(LimiterCaller)
+		}
+	}
+}
+
+// limitCaller 返回限流使用的调用方: 鉴权后的身份或者连接的远端地址
+func limitCaller(c *Context) string {
+	if id, ok := auth.FromContext(c); ok {
+		return "id:" + id.Subject
+	}
+	host, _, err := net.SplitHostPort(c.Request.RemoteAddr)
+	if err != nil {
+		host = c.Request.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// AdaptiveLimit limits the requests of every route with a BBR adaptive limiter, conf can be nil
+// 每个路由一个自适应限流器, 根据cpu使用率和并发请求数拒绝请求
+func AdaptiveLimit(conf *ratelimit.BBRConfig) HandlerFunc {
+	group := ratelimit.NewGroup(func() ratelimit.Limiter {
+		return ratelimit.NewBBR(conf)
+	})
+	return func(c *Context) {
+		done, err := group.Allow(c.RoutePath)
+		if err != nil {
+			c.abortLimited(LimiterAdaptive)
+			return
+		}
+		defer done()
+		c.Next()
+	}
+}
+
+// abortLimited 记录被限流的请求, 返回ecode.LimitExceed并中断处理链
+func (c *Context) abortLimited(limiter string) {
+	path := c.RoutePath
+	if path == "" {
+		path = _metricUnmatchedPath
+	}
+	_metricServerLimited.Inc(metricMethod(c.Request.Method), path, limiter)
+	c.engine.guardLock.RLock()
+	hooks := c.engine.limitHooks
+	c.engine.guardLock.RUnlock()
+	for _, h := range hooks {
+		h(c, limiter)
+	}
+	c.JSON(nil, ecode.LimitExceed)
+	c.Abort()
+}
@@ -0,0 +1,187 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// BBRConfig is the config of the adaptive limiter
+type BBRConfig struct {
+	// Window 统计通过请求数和耗时的滑动窗口, 默认10s
+	Window time.Duration
+	// Buckets 窗口内的桶个数, 默认100
+	Buckets int
+	// CPUThreshold cpu使用率超过阈值时开始限流, 千分比, 默认800
+	CPUThreshold int64
+	// CPU 返回cpu使用率的千分比, 默认为CPUUsage
+	CPU func() int64
+}
+
+// BBRStat is the snapshot of the adaptive limiter
+type BBRStat struct {
+	CPU         int64
+	InFlight    int64
+	MaxInFlight int64
+	// MaxPass 窗口内单个桶的最大通过请求数
+	MaxPass int64
+	// MinRT 窗口内桶的最小平均耗时
+	MinRT time.Duration
+}
+
+// bbrBucket 一个桶内完成的请求数和总耗时
+type bbrBucket struct {
+	pass int64
+	rt   time.Duration
+}
+
+// BBR is an adaptive limiter inspired by TCP BBR: when the CPU usage is over the threshold,
+// the requests are rejected if the in-flight requests exceed maxPass * minRT estimated in the window,
+// i.e. the throughput the service handled best. It keeps rejecting in 1s after the CPU usage drops
+// to avoid jittering
+// 自适应限流: cpu超过阈值时, 并发请求数超过窗口内估算的最大吞吐 maxPass * minRT 的请求会被拒绝,
+// cpu下降后的1s内仍然按并发判断, 避免抖动
+type BBR struct {
+	conf      BBRConfig
+	bucketDur time.Duration
+	inFlight  int64
+	// prevDrop 上次拒绝请求的时间, 0表示冷却已经结束
+	prevDrop int64
+
+	mu      sync.Mutex
+	buckets []bbrBucket
+	// 当前桶的下标和开始时间
+	offset int
+	start  time.Time
+}
+
+var _ Limiter = &BBR{}
+
+// NewBBR new an adaptive limiter, conf can be nil
+func NewBBR(conf *BBRConfig) *BBR {
+	c := BBRConfig{}
+	if conf != nil {
+		c = *conf
+	}
+	if c.Window <= 0 {
+		c.Window = 10 * time.Second
+	}
+	if c.Buckets <= 0 {
+		c.Buckets = 100
+	}
+	if c.CPUThreshold <= 0 {
+		c.CPUThreshold = 800
+	}
+	if c.CPU == nil {
+		c.CPU = CPUUsage
+	}
+	bucketDur := c.Window / time.Duration(c.Buckets)
+	if bucketDur <= 0 {
+		bucketDur = time.Millisecond
+	}
+	return &BBR{
+		conf:      c,
+		bucketDur: bucketDur,
+		buckets:   make([]bbrBucket, c.Buckets),
+		start:     time.Now(),
+	}
+}
+
+// Allow implements Limiter
+func (l *BBR) Allow() (func(), error) {
+	if l.shouldDrop(time.Now()) {
+		return nil, ErrLimitExceed
+	}
+	atomic.AddInt64(&l.inFlight, 1)
+	start := time.Now()
+	return func() {
+		now := time.Now()
+		atomic.AddInt64(&l.inFlight, -1)
+		l.mu.Lock()
+		b := l.current(now)
+		b.pass++
+		b.rt += now.Sub(start)
+		l.mu.Unlock()
+	}, nil
+}
+
+// Stat returns the snapshot of the limiter
+func (l *BBR) Stat() BBRStat {
+	maxPass, minRT := l.window(time.Now())
+	return BBRStat{
+		CPU:         l.conf.CPU(),
+		InFlight:    atomic.LoadInt64(&l.inFlight),
+		MaxInFlight: l.maxInFlight(maxPass, minRT),
+		MaxPass:     maxPass,
+		MinRT:       minRT,
+	}
+}
+
+func (l *BBR) shouldDrop(now time.Time) bool {
+	inFlight := atomic.LoadInt64(&l.inFlight)
+	if l.conf.CPU() < l.conf.CPUThreshold {
+		prev := atomic.LoadInt64(&l.prevDrop)
+		if prev == 0 {
+			return false
+		}
+		if now.UnixNano()-prev > int64(time.Second) {
+			atomic.StoreInt64(&l.prevDrop, 0)
+			return false
+		}
+		return inFlight > 1 && inFlight > l.maxInFlight(l.window(now))
+	}
+	if inFlight <= 1 || inFlight <= l.maxInFlight(l.window(now)) {
+		return false
+	}
+	atomic.StoreInt64(&l.prevDrop, now.UnixNano())
+	return true
+}
+
+// maxInFlight 按窗口内的最大吞吐估算的并发请求数
+func (l *BBR) maxInFlight(maxPass int64, minRT time.Duration) int64 {
+	perSecond := float64(time.Second) / float64(l.bucketDur)
+	return int64(math.Floor(float64(maxPass)*perSecond*minRT.Seconds() + 0.5))
+}
+
+// window 返回窗口内已经结束的桶的最大通过请求数和最小平均耗时, 没有数据时为1和1ms
+func (l *BBR) window(now time.Time) (maxPass int64, minRT time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.current(now)
+	for i := range l.buckets {
+		b := l.buckets[i]
+		if i == l.offset || b.pass == 0 {
+			continue
+		}
+		if b.pass > maxPass {
+			maxPass = b.pass
+		}
+		if rt := b.rt / time.Duration(b.pass); minRT == 0 || rt < minRT {
+			minRT = rt
+		}
+	}
+	if maxPass == 0 {
+		maxPass = 1
+	}
+	if minRT <= 0 {
+		minRT = time.Millisecond
+	}
+	return
+}
+
+// current 滑动到now所在的桶并清空跳过的桶, 调用者需要持有mu
+func (l *BBR) current(now time.Time) *bbrBucket {
+	steps := int(now.Sub(l.start) / l.bucketDur)
+	if steps > len(l.buckets) {
+		steps = len(l.buckets)
+	}
+	for i := 0; i < steps; i++ {
+		l.offset = (l.offset + 1) % len(l.buckets)
+		l.buckets[l.offset] = bbrBucket{}
+	}
+	if steps > 0 {
+		l.start = l.start.Add(now.Sub(l.start) / l.bucketDur * l.bucketDur)
+	}
+	return &l.buckets[l.offset]
+}
@@ -0,0 +1,52 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// TokenBucket puts rate tokens into the bucket per second and holds burst tokens at most,
+// every request takes one token
+// 令牌桶, 每秒放入rate个令牌, 最多存放burst个, 每个请求消耗一个令牌
+type TokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+var _ Limiter = &TokenBucket{}
+
+// NewTokenBucket new a token bucket which is full, burst defaults to ceil(rate) if it's not positive
+func NewTokenBucket(rate float64, burst int) *TokenBucket {
+	b := float64(burst)
+	if burst <= 0 {
+		b = math.Ceil(rate)
+	}
+	return &TokenBucket{rate: rate, burst: b, tokens: b, last: time.Now()}
+}
+
+// Allow implements Limiter
+func (b *TokenBucket) Allow() (func(), error) {
+	if !b.AllowAt(time.Now()) {
+		return nil, ErrLimitExceed
+	}
+	return noop, nil
+}
+
+// AllowAt takes a token at now, it returns false if the bucket is empty
+func (b *TokenBucket) AllowAt(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(b.burst, b.tokens+elapsed*b.rate)
+		b.last = now
+	}
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
@@ -0,0 +1,84 @@
+package ratelimit
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// cpu使用率的采样间隔和滑动平均的衰减系数
+	_cpuInterval = 250 * time.Millisecond
+	_cpuDecay    = 0.95
+)
+
+var (
+	_cpuOnce  sync.Once
+	_cpuUsage int64
+)
+
+// CPUUsage returns the CPU usage of the host in permille, i.e. 0-1000, as a moving average
+// sampled from /proc/stat, it's always 0 if /proc/stat is not available
+// 主机的cpu使用率, 千分比, 读取/proc/stat后做滑动平均, 不支持的系统上始终为0
+func CPUUsage() int64 {
+	_cpuOnce.Do(startCPUSampler)
+	return atomic.LoadInt64(&_cpuUsage)
+}
+
+func startCPUSampler() {
+	total, idle, err := readProcStat()
+	if err != nil {
+		return
+	}
+	go func() {
+		var usage float64
+		ticker := time.NewTicker(_cpuInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			t, i, err := readProcStat()
+			if err != nil || t <= total {
+				continue
+			}
+			cur := 1000 * (1 - float64(i-idle)/float64(t-total))
+			total, idle = t, i
+			usage = usage*_cpuDecay + cur*(1-_cpuDecay)
+			atomic.StoreInt64(&_cpuUsage, int64(usage))
+		}
+	}()
+}
+
+// readProcStat 读取/proc/stat中所有cpu的总时间和空闲时间
+func readProcStat() (total, idle uint64, err error) {
+	f, err := os.Open("/proc/stat")
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) < 5 || fields[0] != "cpu" {
+			continue
+		}
+		for i, field := range fields[1:] {
+			v, err := strconv.ParseUint(field, 10, 64)
+			if err != nil {
+				return 0, 0, err
+			}
+			total += v
+			// idle 和 iowait
+			if i == 3 || i == 4 {
+				idle += v
+			}
+		}
+		return
+	}
+	if err = s.Err(); err == nil {
+		err = os.ErrNotExist
+	}
+	return
+}
@@ -0,0 +1,85 @@
+// Package ratelimit provides the limiters protecting a service from overload:
+// token bucket, keyed limiters e.g. per caller, and a BBR-style adaptive limiter
+package ratelimit
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// ErrLimitExceed is returned by Allow if the request is rejected
+var ErrLimitExceed = errors.New("ratelimit: limit exceeded")
+
+// Limiter decides whether a request is allowed, done must be called after the allowed request finished
+// 请求被允许时返回done, 请求结束后必须调用done
+type Limiter interface {
+	Allow() (done func(), err error)
+}
+
+// noop 不需要统计请求结束的limiter返回的done
+func noop() {}
+
+// Group is a set of limiters keyed by a string, e.g. the route,
+// the limiter of a key is created by New on the first use.
+// The group created by NewGroup never removes the limiters, so the number of the keys should be bounded,
+// use NewBoundedGroup for the keys controlled by the clients, e.g. the callers
+// 按key区分的limiter, 首次使用时创建. NewGroup创建的group不会删除limiter, key的个数应该是有限的,
+// 客户端可以控制的key使用NewBoundedGroup
+type Group struct {
+	New func() Limiter
+
+	mu       sync.Mutex
+	limiters map[string]*list.Element
+	// lru 最近使用的在前面, size 为0时不淘汰
+	lru  *list.List
+	size int
+}
+
+// lruEntry lru中的元素
+type lruEntry struct {
+	key     string
+	limiter Limiter
+}
+
+// NewGroup new a limiter group without eviction
+func NewGroup(new func() Limiter) *Group {
+	return NewBoundedGroup(new, 0)
+}
+
+// NewBoundedGroup new a limiter group keeping at most size limiters, the least recently used
+// limiter is evicted when it's full and its key starts over with a new limiter. size 0 means no eviction
+func NewBoundedGroup(new func() Limiter, size int) *Group {
+	return &Group{New: new, limiters: make(map[string]*list.Element), lru: list.New(), size: size}
+}
+
+// Get returns the limiter of the key
+func (g *Group) Get(key string) Limiter {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if e, ok := g.limiters[key]; ok {
+		g.lru.MoveToFront(e)
+		return e.Value.(*lruEntry).limiter
+	}
+	l := g.New()
+	g.limiters[key] = g.lru.PushFront(&lruEntry{key: key, limiter: l})
+	if g.size > 0 && g.lru.Len() > g.size {
+		oldest := g.lru.Back()
+		g.lru.Remove(oldest)
+		delete(g.limiters, oldest.Value.(*lruEntry).key)
+	}
+	return l
+}
+
+// Len returns the number of the limiters in the group
+func (g *Group) Len() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.lru.Len()
+}
+
+// Allow is a shortcut for g.Get(key).Allow()
+func (g *Group) Allow(key string) (func(), error) {
+	return g.Get(key).Allow()
+}
@@ -0,0 +1,25 @@
+package ratelimit
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestBoundedGroupEvicts(t *testing.T) {
+	g := NewBoundedGroup(func() Limiter { return NewTokenBucket(1, 1) }, 2)
+	first := g.Get("a")
+	for i := 0; i < 10; i++ {
+		g.Get(strconv.Itoa(i))
+	}
+	if n := g.Len(); n != 2 {
+		t.Fatalf("len: %d", n)
+	}
+	if g.Get("a") == first {
+		t.Fatal("least recently used limiter is not evicted")
+	}
+	recent := g.Get("b")
+	g.Get("c")
+	if g.Get("b") != recent {
+		t.Fatal("recently used limiter is evicted")
+	}
+}
@@ -0,0 +1,27 @@
+package pudding
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCallerLimitIgnoresCallerHeader(t *testing.T) {
+	engine := New()
+	engine.GET("/c", CallerLimit(1, 1), func(c *Context) { c.String(http.StatusOK, "ok") })
+	serve := func(caller string) string {
+		req := httptest.NewRequest(http.MethodGet, "/c", nil)
+		req.Header.Set(_httpHeaderUser, caller)
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w.Body.String()
+	}
+	if body := serve("a"); body != "ok" {
+		t.Fatal(body)
+	}
+	// 修改调用方请求头不能绕过限流
+	if body := serve("b"); !strings.Contains(body, "-509") {
+		t.Fatalf("limit skipped by changing the caller header: %s", body)
+	}
+}
@@ -0,0 +1,129 @@
+package pudding
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/bdjimmy/pudding/auth"
+	"github.com/bdjimmy/pudding/ecode"
+	"github.com/bdjimmy/pudding/rbac"
+	"github.com/pkg/errors"
+)
+
+// _anyMethod group和RouteMeta声明的要求对路径上的所有方法生效
+const _anyMethod = "*"
+
+// RequireRoles declares that the callers of the routes under the group must have any of the roles,
+// it's keyed by the color of the group and the group prefix, e.g. /admin/*, and enforced by Authorize
+// 声明group下的路由要求的角色, 染色group的要求只对同一染色的路由生效
+func (group *RouterGroup) RequireRoles(roles ...string) *RouterGroup {
+	group.engine.require(group.color, _anyMethod, joinPaths(group.basePath, _prefixWildcard), func(req *rbac.Requirement) {
+		req.Roles = append([]string(nil), roles...)
+	})
+	return group
+}
+
+// RequirePermissions declares that the callers of the routes under the group must have all the permissions
+func (group *RouterGroup) RequirePermissions(perms ...string) *RouterGroup {
+	group.engine.require(group.color, _anyMethod, joinPaths(group.basePath, _prefixWildcard), func(req *rbac.Requirement) {
+		req.Permissions = append([]string(nil), perms...)
+	})
+	return group
+}
+
+// Require declares the requirement of the route registered with httpMethod at relativePath under the group,
+// it replaces the requirement declared before on the same route
+// 声明单个路由要求的角色和权限, 只对该方法生效
+func (group *RouterGroup) Require(httpMethod, relativePath string, req rbac.Requirement) *RouterGroup {
+	group.engine.require(group.color, httpMethod, group.calculateAbsolutePath(relativePath), func(r *rbac.Requirement) {
+		r.Roles = append([]string(nil), req.Roles...)
+		r.Permissions = append([]string(nil), req.Permissions...)
+	})
+	return group
+}
+
+// Authorize enforces the roles and the permissions declared by RequireRoles, RequirePermissions,
+// Require and RouteMeta on the route and all its parent groups, the caller must meet all of them.
+// The requirements without color apply to the color routes too, the color lanes are never weaker
+// than the default routes, and the requirements of a color group only apply to the routes of the color.
+// The identity is read from the metadata written by the auth middleware, so it must run after them,
+// e.g. group.UseFunc(Authorize(provider)) with MethodConfig.Auth. The requests without identity get
+// 401 with ecode.Unauthorized, the requests not meeting the requirements get 403 with ecode.AccessDenied
+// and the provider errors get 500 with ecode.ServerErr
+// 校验路由和所有上级group声明的角色和权限, 需要在鉴权中间件之后执行
+func Authorize(p rbac.Provider) HandlerFunc {
+	return func(c *Context) {
+		reqs := c.engine.requirements(c.routeColor, c.method, c.RoutePath)
+		if len(reqs) == 0 {
+			return
+		}
+		id, ok := auth.FromContext(c)
+		if !ok {
+			c.abortUnauthorized("", nil)
+			return
+		}
+		if err := rbac.Check(c, p, id, reqs...); err != nil {
+			if errors.Is(err, rbac.ErrForbidden) {
+				c.renderJSON(http.StatusForbidden, nil, ecode.Wrap(ecode.AccessDenied, err))
+			} else {
+				c.renderJSON(http.StatusInternalServerError, nil, ecode.Wrap(ecode.ServerErr, err))
+			}
+			c.Abort()
+		}
+	}
+}
+
+// require 修改路由或group前缀上声明的要求
+func (engine *Engine) require(color, method, path string, set func(req *rbac.Requirement)) {
+	engine.metaLock.Lock()
+	defer engine.metaLock.Unlock()
+	if engine.requires == nil {
+		engine.requires = make(map[string]rbac.Requirement)
+	}
+	key := requireKey(color, method, path)
+	req := engine.requires[key]
+	set(&req)
+	engine.requires[key] = req
+}
+
+// requirements 先读取默认路由的要求, 再读取染色路由的要求,
+// 每一组都从根到路由依次读取group前缀和路由本身声明的角色和权限
+func (engine *Engine) requirements(color, method, route string) (reqs []rbac.Requirement) {
+	if route == "" {
+		return
+	}
+	engine.metaLock.RLock()
+	defer engine.metaLock.RUnlock()
+	if len(engine.requires) == 0 {
+		return
+	}
+	colors := []string{""}
+	if color != "" {
+		colors = append(colors, color)
+	}
+	for _, color := range colors {
+		add := func(path string) {
+			for _, m := range [...]string{_anyMethod, method} {
+				if req := engine.requires[requireKey(color, m, path)]; !req.IsZero() {
+					reqs = append(reqs, req)
+				}
+			}
+		}
+		for i := 0; i < len(route); i++ {
+			if route[i] == '/' {
+				add(route[:i+1] + _prefixWildcard)
+			}
+		}
+		// group的基础路径本身也属于group, 例如 /admin 属于 /admin/*
+		if !strings.HasSuffix(route, "/") {
+			add(route + "/" + _prefixWildcard)
+		}
+		add(route)
+	}
+	return
+}
+
+// requireKey 声明的要求的key, method为_anyMethod时对所有方法生效
+func requireKey(color, method, path string) string {
+	return color + "\x00" + method + " " + path
+}
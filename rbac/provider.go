@@ -0,0 +1,109 @@
+package rbac
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+
+	"github.com/bdjimmy/pudding/auth"
+	"github.com/pkg/errors"
+)
+
+// Policy is the roles of the subjects and the permissions of the roles, e.g.
+// {"subjects": {"alice": ["admin"]}, "roles": {"admin": ["user:read", "user:write"]}}
+type Policy struct {
+	Subjects map[string][]string `json:"subjects"`
+	Roles    map[string][]string `json:"roles"`
+}
+
+// Memory is the in-memory Provider keyed by the subject of the identity
+// 内存中的策略, 按调用方的subject查找角色
+type Memory struct {
+	mu     sync.RWMutex
+	policy Policy
+}
+
+var _ Provider = &Memory{}
+
+// NewMemory new an in-memory provider with the policy, policy can be nil
+func NewMemory(policy *Policy) *Memory {
+	m := &Memory{}
+	m.SetPolicy(policy)
+	return m
+}
+
+// SetPolicy replaces the whole policy
+func (m *Memory) SetPolicy(policy *Policy) {
+	p := Policy{Subjects: make(map[string][]string), Roles: make(map[string][]string)}
+	if policy != nil {
+		for s, roles := range policy.Subjects {
+			p.Subjects[s] = append([]string(nil), roles...)
+		}
+		for r, perms := range policy.Roles {
+			p.Roles[r] = append([]string(nil), perms...)
+		}
+	}
+	m.mu.Lock()
+	m.policy = p
+	m.mu.Unlock()
+}
+
+// SetRoles sets the roles of the subject
+func (m *Memory) SetRoles(subject string, roles ...string) {
+	m.mu.Lock()
+	m.policy.Subjects[subject] = append([]string(nil), roles...)
+	m.mu.Unlock()
+}
+
+// SetPermissions sets the permissions of the role
+func (m *Memory) SetPermissions(role string, perms ...string) {
+	m.mu.Lock()
+	m.policy.Roles[role] = append([]string(nil), perms...)
+	m.mu.Unlock()
+}
+
+// Roles implements Provider
+func (m *Memory) Roles(_ context.Context, id *auth.Identity) ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.policy.Subjects[id.Subject], nil
+}
+
+// Permissions implements Provider
+func (m *Memory) Permissions(_ context.Context, role string) ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.policy.Roles[role], nil
+}
+
+// File is the Provider loading the policy from a JSON file, see Policy for the format
+// 从JSON文件加载的策略, 调用Reload重新加载
+type File struct {
+	*Memory
+	file string
+}
+
+// NewFile new a file-backed provider and loads the file
+func NewFile(file string) (*File, error) {
+	f := &File{Memory: NewMemory(nil), file: file}
+	if err := f.Reload(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Reload reloads the policy from the file, the old policy is kept if it fails
+func (f *File) Reload() error {
+	file, err := os.Open(f.file)
+	if err != nil {
+		return errors.Wrap(err, "rbac: open policy file")
+	}
+	defer file.Close()
+	policy := &Policy{}
+	if err = json.NewDecoder(file).Decode(policy); err != nil {
+		return errors.Wrapf(err, "rbac: decode policy file %s", f.file)
+	}
+	f.SetPolicy(policy)
+	return nil
+}
@@ -0,0 +1,101 @@
+// Package rbac decides whether an authenticated identity meets the role and permission
+// requirements of a route, the roles and the permissions come from a pluggable Provider
+package rbac
+
+import (
+	"context"
+
+	"github.com/bdjimmy/pudding/auth"
+	"github.com/pkg/errors"
+)
+
+// AnyPermission is the permission granting all the permissions, e.g. for the super admin
+const AnyPermission = "*"
+
+// ErrForbidden is returned by Check if the identity doesn't meet the requirement
+var ErrForbidden = errors.New("rbac: forbidden")
+
+// Provider provides the roles of the identities and the permissions of the roles
+// 策略提供者, 返回调用方的角色和角色的权限
+type Provider interface {
+	// Roles 返回调用方的角色, 可以来自本地配置, 也可以来自JWT中的声明
+	Roles(ctx context.Context, id *auth.Identity) ([]string, error)
+	// Permissions 返回角色拥有的权限
+	Permissions(ctx context.Context, role string) ([]string, error)
+}
+
+// Requirement is the roles and the permissions required by a route or a group,
+// the identity must have any of Roles and all of Permissions, the empty fields are not checked
+// 路由或group要求的角色和权限: 拥有Roles中的任意一个角色, 并且拥有Permissions中的所有权限
+type Requirement struct {
+	Roles       []string `json:"roles,omitempty"`
+	Permissions []string `json:"permissions,omitempty"`
+}
+
+// IsZero reports whether nothing is required
+func (r Requirement) IsZero() bool {
+	return len(r.Roles) == 0 && len(r.Permissions) == 0
+}
+
+// Check checks the requirements in order, the identity must meet all of them,
+// the error wraps ErrForbidden if it doesn't, otherwise it's the error of the provider
+func Check(ctx context.Context, p Provider, id *auth.Identity, reqs ...Requirement) error {
+	var (
+		roles  []string
+		perms  map[string]bool
+		loaded bool
+	)
+	for _, req := range reqs {
+		if req.IsZero() {
+			continue
+		}
+		if !loaded {
+			var err error
+			if roles, err = p.Roles(ctx, id); err != nil {
+				return errors.WithMessage(err, "rbac: get roles")
+			}
+			if perms, err = permissions(ctx, p, roles); err != nil {
+				return err
+			}
+			loaded = true
+		}
+		if len(req.Roles) > 0 && !hasAny(roles, req.Roles) {
+			return errors.Wrapf(ErrForbidden, "subject:%s requires any role of %v", id.Subject, req.Roles)
+		}
+		if perms[AnyPermission] {
+			continue
+		}
+		for _, perm := range req.Permissions {
+			if !perms[perm] {
+				return errors.Wrapf(ErrForbidden, "subject:%s requires permission %s", id.Subject, perm)
+			}
+		}
+	}
+	return nil
+}
+
+// permissions 合并所有角色的权限
+func permissions(ctx context.Context, p Provider, roles []string) (map[string]bool, error) {
+	perms := make(map[string]bool)
+	for _, role := range roles {
+		ps, err := p.Permissions(ctx, role)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "rbac: get permissions of role %s", role)
+		}
+		for _, perm := range ps {
+			perms[perm] = true
+		}
+	}
+	return perms, nil
+}
+
+func hasAny(have, want []string) bool {
+	for _, w := range want {
+		for _, h := range have {
+			if h == w {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,129 @@
+package rbac
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bdjimmy/pudding/auth"
+	"github.com/pkg/errors"
+)
+
+// errProvider 返回错误的Provider, 记录调用次数
+type errProvider struct {
+	calls int
+}
+
+func (p *errProvider) Roles(context.Context, *auth.Identity) ([]string, error) {
+	p.calls++
+	return nil, errors.New("unavailable")
+}
+
+func (p *errProvider) Permissions(context.Context, string) ([]string, error) {
+	return nil, nil
+}
+
+func TestCheck(t *testing.T) {
+	p := NewMemory(&Policy{
+		Subjects: map[string][]string{"alice": {"admin"}, "bob": {"reader", "writer"}, "root": {"root"}},
+		Roles: map[string][]string{
+			"admin":  {"user:read"},
+			"reader": {"user:read"},
+			"writer": {"user:write"},
+			"root":   {AnyPermission},
+		},
+	})
+	cases := []struct {
+		subject string
+		reqs    []Requirement
+		denied  bool
+	}{
+		{"alice", nil, false},
+		{"alice", []Requirement{{}}, false},
+		{"alice", []Requirement{{Roles: []string{"guest", "admin"}}}, false},
+		{"alice", []Requirement{{Roles: []string{"writer"}}}, true},
+		{"bob", []Requirement{{Permissions: []string{"user:read", "user:write"}}}, false},
+		{"alice", []Requirement{{Permissions: []string{"user:read", "user:write"}}}, true},
+		{"bob", []Requirement{{Roles: []string{"reader"}}, {Roles: []string{"admin"}}}, true},
+		{"root", []Requirement{{Permissions: []string{"anything"}}}, false},
+		{"root", []Requirement{{Roles: []string{"admin"}, Permissions: []string{"anything"}}}, true},
+		{"nobody", []Requirement{{Permissions: []string{"user:read"}}}, true},
+	}
+	for _, cs := range cases {
+		err := Check(context.Background(), p, &auth.Identity{Subject: cs.subject}, cs.reqs...)
+		if cs.denied != errors.Is(err, ErrForbidden) || !cs.denied && err != nil {
+			t.Errorf("%s %+v: %v", cs.subject, cs.reqs, err)
+		}
+	}
+}
+
+func TestCheckProviderError(t *testing.T) {
+	p := &errProvider{}
+	id := &auth.Identity{Subject: "alice"}
+	// 没有要求时不调用Provider
+	if err := Check(context.Background(), p, id, Requirement{}); err != nil || p.calls != 0 {
+		t.Fatalf("err(%v) calls(%d)", err, p.calls)
+	}
+	err := Check(context.Background(), p, id, Requirement{Roles: []string{"admin"}}, Requirement{Roles: []string{"admin"}})
+	if err == nil || errors.Is(err, ErrForbidden) || p.calls != 1 {
+		t.Fatalf("err(%v) calls(%d)", err, p.calls)
+	}
+}
+
+func TestMemory(t *testing.T) {
+	policy := &Policy{Subjects: map[string][]string{"alice": {"admin"}}, Roles: map[string][]string{"admin": {"a"}}}
+	m := NewMemory(policy)
+	// 策略被复制, 修改传入的策略不影响Provider
+	policy.Subjects["alice"][0] = "guest"
+	if roles, _ := m.Roles(context.Background(), &auth.Identity{Subject: "alice"}); len(roles) != 1 || roles[0] != "admin" {
+		t.Fatalf("roles: %v", roles)
+	}
+	m.SetRoles("bob", "writer")
+	m.SetPermissions("writer", "b", "c")
+	if roles, _ := m.Roles(context.Background(), &auth.Identity{Subject: "bob"}); len(roles) != 1 || roles[0] != "writer" {
+		t.Fatalf("roles: %v", roles)
+	}
+	if perms, _ := m.Permissions(context.Background(), "writer"); len(perms) != 2 {
+		t.Fatalf("permissions: %v", perms)
+	}
+	if roles, _ := NewMemory(nil).Roles(context.Background(), &auth.Identity{Subject: "alice"}); len(roles) != 0 {
+		t.Fatalf("roles: %v", roles)
+	}
+}
+
+func TestFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "policy.json")
+	write := func(s string) {
+		if err := os.WriteFile(file, []byte(s), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := NewFile(file); err == nil {
+		t.Fatal("missing file loaded")
+	}
+	write(`{"subjects": {"alice": ["admin"]}, "roles": {"admin": ["user:read"]}}`)
+	f, err := NewFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := &auth.Identity{Subject: "alice"}
+	if err = Check(context.Background(), f, id, Requirement{Permissions: []string{"user:read"}}); err != nil {
+		t.Fatal(err)
+	}
+	// 加载失败时保留之前的策略
+	write(`{"subjects":`)
+	if err = f.Reload(); err == nil {
+		t.Fatal("malformed file loaded")
+	}
+	if err = Check(context.Background(), f, id, Requirement{Roles: []string{"admin"}}); err != nil {
+		t.Fatal(err)
+	}
+	write(`{"subjects": {"alice": ["guest"]}}`)
+	if err = f.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if err = Check(context.Background(), f, id, Requirement{Roles: []string{"admin"}}); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("reloaded policy not applied: %v", err)
+	}
+}
@@ -0,0 +1,67 @@
+package pudding
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/bdjimmy/pudding/auth"
+	"github.com/bdjimmy/pudding/rbac"
+)
+
+func TestAuthorize(t *testing.T) {
+	engine := New()
+	provider := rbac.NewMemory(&rbac.Policy{
+		Subjects: map[string][]string{"alice": {"admin"}, "bob": {"user"}, "carol": {"canary"}},
+		Roles:    map[string][]string{"admin": {"user:read", "user:write"}, "user": {"user:read"}, "canary": {"user:read"}},
+	})
+	// 测试用的鉴权中间件, 从请求头读取调用方
+	identify := func(c *Context) {
+		if sub := c.Request.Header.Get("X-Test-Subject"); sub != "" {
+			c.authenticated(&auth.Identity{Subject: sub}, nil, "")
+		}
+	}
+	ok := func(c *Context) { c.String(http.StatusOK, "ok") }
+	api := engine.Group("/api", identify, Authorize(provider))
+	api.GET("/user", ok)
+	api.POST("/user", ok)
+	api.GET("/public", ok)
+	api.Require(http.MethodPost, "/user", rbac.Requirement{Permissions: []string{"user:write"}})
+	api.Group("/user").RequirePermissions("user:read")
+	canary := api.Color("canary")
+	canary.GET("/canary", ok)
+	canary.RequireRoles("canary")
+	api.GET("/canary", ok)
+
+	cases := []struct {
+		method, path, subject, color string
+		code, status                 int
+	}{
+		{http.MethodGet, "/api/public", "", "", 0, http.StatusOK},
+		{http.MethodGet, "/api/user", "", "", -401, http.StatusUnauthorized},
+		{http.MethodGet, "/api/user", "bob", "", 0, http.StatusOK},
+		{http.MethodPost, "/api/user", "bob", "", -403, http.StatusForbidden},
+		{http.MethodPost, "/api/user", "alice", "", 0, http.StatusOK},
+		{http.MethodGet, "/api/canary", "bob", "", 0, http.StatusOK},
+		{http.MethodGet, "/api/canary", "bob", "canary", -403, http.StatusForbidden},
+		{http.MethodGet, "/api/canary", "carol", "canary", 0, http.StatusOK},
+		{http.MethodGet, "/api/public", "bob", "canary", 0, http.StatusOK},
+	}
+	for _, cs := range cases {
+		req := httptest.NewRequest(cs.method, cs.path, nil)
+		if cs.subject != "" {
+			req.Header.Set("X-Test-Subject", cs.subject)
+		}
+		if cs.color != "" {
+			req.Header.Set(_httpHeaderColor, cs.color)
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		body := w.Body.String()
+		if w.Code != cs.status || cs.code == 0 && body != "ok" || cs.code != 0 && !strings.Contains(body, `"code":`+strconv.Itoa(cs.code)) {
+			t.Errorf("%s %s subject(%s) color(%s): %d %s", cs.method, cs.path, cs.subject, cs.color, w.Code, body)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package pudding
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"runtime"
+
+	"github.com/bdjimmy/pudding/ecode"
+	"github.com/bdjimmy/pudding/metadata"
+)
+
+const (
+	// 记录panic堆栈的最大字节数
+	_stackSize = 64 << 10 // 64KB
+)
+
+// PanicReporter reports the panics recovered by the Recovery middleware, e.g. to an alarm system
+type PanicReporter interface {
+	Report(c *Context, err interface{}, stack []byte)
+}
+
+// PanicReporterFunc is an adapter to allow the use of ordinary functions as PanicReporter
+type PanicReporterFunc func(c *Context, err interface{}, stack []byte)
+
+// Report calls f(c, err, stack)
+func (f PanicReporterFunc) Report(c *Context, err interface{}, stack []byte) {
+	f(c, err, stack)
+}
+
+// RecoveryConfig is the Recovery middleware config
+type RecoveryConfig struct {
+	// Status 为true时只返回http 500, 否则返回ServerErr的JSON
+	Status bool
+	// Reporter 上报panic, 可以为空
+	Reporter PanicReporter
+}
+
+// Recovery returns a middleware that recovers from any panics and renders a ServerErr JSON
+func Recovery() HandlerFunc {
+	return RecoveryWithConfig(nil)
+}
+
+// RecoveryWithConfig returns a middleware that recovers from any panics,
+// logs the stack with the request metadata, sets c.Error and renders the error response
+func RecoveryWithConfig(conf *RecoveryConfig) HandlerFunc {
+	if conf == nil {
+		conf = &RecoveryConfig{}
+	}
+	return func(c *Context) {
+		defer func() {
+			var rawErr interface{}
+			if rawErr = recover(); rawErr == nil {
+				return
+			}
+			// http.ErrAbortHandler 是用来中断响应的, 交给net/http处理
+			if rawErr == http.ErrAbortHandler {
+				panic(rawErr)
+			}
+			buf := make([]byte, _stackSize)
+			buf = buf[:runtime.Stack(buf, false)]
+			md, _ := metadata.FromContext(c)
+			log.Printf("pudding: panic recovered: %s %s caller(%v) remote_ip(%v) error(%v)\n%s",
+				c.Request.Method, c.Request.URL.Path, md[metadata.Caller], md[metadata.RemoteIP], rawErr, buf)
+			if conf.Reporter != nil {
+				conf.Reporter.Report(c, rawErr, buf)
+			}
+			c.recovered(conf.Status, rawErr)
+		}()
+		c.Next()
+	}
+}
+
+// recovered 渲染错误响应并中断处理链, 响应头已经写出时只中断处理链
+func (c *Context) recovered(status bool, rawErr interface{}) {
+	written := false
+	if rw, ok := c.Writer.(ResponseWriter); ok {
+		written = rw.Written()
+	}
+	if !written {
+		if status {
+			c.Status(http.StatusInternalServerError)
+		} else {
+			c.JSON(nil, ecode.ServerErr)
+		}
+	}
+	c.Error = ecode.Wrap(ecode.ServerErr, fmt.Errorf("panic: %v", rawErr))
+	c.Abort()
+}
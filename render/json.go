@@ -12,7 +12,7 @@ type JSON struct {
 	Code    int         `json:"code"`
 	Message string      `json:"message"`
 	TTL     int         `json:"ttl"`
-	Data    interface{} `json:"data, omitempty"`
+	Data    interface{} `json:"data,omitempty"`
 }
 
 // Render writes data with json ContentType
@@ -0,0 +1,97 @@
+package pudding
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// ResponseWriter records the status and the size of the response,
+// it's used by the middleware after the handlers, e.g. metrics and access log
+// 记录响应的状态码和大小, 供中间件在处理函数执行完之后使用
+type ResponseWriter interface {
+	http.ResponseWriter
+	http.Flusher
+	http.Hijacker
+
+	// Status returns the HTTP response status code of the current request.
+	Status() int
+	// Size returns the number of bytes already written into the response http body.
+	Size() int
+	// Written returns true if the response header was already written.
+	Written() bool
+}
+
+var _ ResponseWriter = &responseWriter{}
+
+type responseWriter struct {
+	http.ResponseWriter
+	size    int
+	status  int
+	written bool
+}
+
+func (w *responseWriter) reset(writer http.ResponseWriter) {
+	w.ResponseWriter = writer
+	w.size = 0
+	w.status = http.StatusOK
+	w.written = false
+}
+
+// WriteHeader writes the status code only once, the later calls are ignored
+func (w *responseWriter) WriteHeader(code int) {
+	if w.written {
+		return
+	}
+	w.status = code
+	w.written = true
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseWriter) Write(data []byte) (n int, err error) {
+	if !w.written {
+		w.WriteHeader(http.StatusOK)
+	}
+	n, err = w.ResponseWriter.Write(data)
+	w.size += n
+	return
+}
+
+func (w *responseWriter) Status() int {
+	return w.status
+}
+
+func (w *responseWriter) Size() int {
+	return w.size
+}
+
+func (w *responseWriter) Written() bool {
+	return w.written
+}
+
+// Flush implements the http.Flusher interface.
+func (w *responseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		if !w.written {
+			w.WriteHeader(http.StatusOK)
+		}
+		f.Flush()
+	}
+}
+
+// Hijack implements the http.Hijacker interface.
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("pudding: response writer does not implement http.Hijacker")
+	}
+	w.written = true
+	return h.Hijack()
+}
+
+// Unwrap returns the original http.ResponseWriter, it's used by http.ResponseController
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -2,6 +2,8 @@ package pudding
 
 import (
 	"regexp"
+
+	"github.com/bdjimmy/pudding/middleware/perf"
 )
 
 // IRouter http router framework interface
@@ -34,8 +36,10 @@ type RouterGroup struct {
 	engine   *Engine
 	// 标记是否是root节点的RouterGroup
 	root     bool
-	// 方法的配置, 拥有公共方法配置的放到一个group中
-	baseConfig *MethodConfig
+	// 染色标记, 不为空时group下的路由只匹配带有该染色标记的请求
+	color string
+	// Handlers开头的全局中间件个数, 路由的MethodConfig在全局中间件之后执行
+	globals int
 }
 
 // RouterGroup 实现了IRouter
@@ -62,12 +66,34 @@ func (group *RouterGroup) Group(relativePath string, handlers ...HandlerFunc) *R
 		basePath: group.calculateAbsolutePath(relativePath),
 		engine:   group.engine,
 		root:     false,
+		color:    group.color,
+		globals:  group.globalCount(),
+	}
+}
+
+// Color creates a new router group with the same base path, the routes registered on it
+// are only matched by the requests with the color, e.g. x-pudding-color: canary,
+// other requests fall back to the routes without color
+// 染色路由: 带有对应染色标记的请求优先匹配, 其他请求仍然匹配默认路由
+func (group *RouterGroup) Color(color string, handlers ...HandlerFunc) *RouterGroup {
+	if color == "" {
+		panic("pudding: color can not be empty")
+	}
+	return &RouterGroup{
+		Handlers: group.combineHandlers(handlers),
+		basePath: group.basePath,
+		engine:   group.engine,
+		root:     false,
+		color:    color,
+		globals:  group.globalCount(),
 	}
 }
 
-// SetMethodConfig is used to set config on specified method
+// SetMethodConfig sets the config on the subtree of the group, e.g. /api/* for the group /api,
+// the routes without their own config use the config of the longest group prefix
+// 为group下的所有路由设置配置, 包括在之后注册的路由
 func (group *RouterGroup) SetMethodConfig(config *MethodConfig) *RouterGroup {
-	group.baseConfig = config
+	group.engine.SetMethodConfig(joinPaths(group.basePath, _prefixWildcard), config)
 	return group
 }
 
@@ -114,14 +140,15 @@ func (group *RouterGroup) DELETE(relativePath string, handlers ...HandlerFunc) I
 }
 
 func (group *RouterGroup) handle(httpMethod, relativePath string, handlers ...HandlerFunc) IRoutes {
-	absolutePath := group.calculateAbsolutePath(relativePath)
-	injections := group.injections(relativePath)
-	handlers = group.combineHandlers(injections, handlers)
-	// 想mux中注册回调函数
-	group.engine.addRoute(httpMethod, absolutePath, handlers...)
-	if group.baseConfig != nil {
-		group.engine.SetMethodConfig(absolutePath, group.baseConfig)
-	}
+	// 处理链由engine生成: 全局中间件, MethodConfig, group中间件, injection, 处理函数
+	group.engine.addRouteEntry(&routeEntry{
+		method:   httpMethod,
+		path:     group.calculateAbsolutePath(relativePath),
+		color:    group.color,
+		group:    group.Handlers,
+		globals:  group.globalCount(),
+		handlers: handlers,
+	})
 	return group.returnObj()
 }
 
@@ -146,6 +173,14 @@ func (group *RouterGroup) combineHandlers(handlerGroups ...[]HandlerFunc) []Hand
 	return mergedHandlers
 }
 
+// globalCount 返回Handlers开头的全局中间件个数, 根节点的中间件都是全局的
+func (group *RouterGroup) globalCount() int {
+	if group.root {
+		return len(group.Handlers)
+	}
+	return group.globals
+}
+
 // calculateAbsolutePath 计算绝对路径
 func (group *RouterGroup) calculateAbsolutePath(relativePath string) string {
 	return joinPaths(group.basePath, relativePath)
@@ -160,14 +195,14 @@ func (group *RouterGroup) returnObj() IRoutes {
 	return group
 }
 
-// injections 根据路径，按照自上而下的正则匹配合适的中间件函数, 需要注意的是匹配成功后就会返回处理函数
-func (group *RouterGroup) injections(relativePath string) []HandlerFunc {
-	absPath := group.calculateAbsolutePath(relativePath)
-	for _, injection := range group.engine.injections {
-		if !injection.pattern.MatchString(absPath) {
-			continue
-		}
-		return injection.handlers
-	}
-	return nil
+// Perf mounts the pprof handlers at relativePath under the group, e.g. group.Perf("/pprof"),
+// the middleware of the group is used to protect them, including rate changing the profile rates
+// 在group下挂载pprof, 通过group的中间件做权限控制
+func (group *RouterGroup) Perf(relativePath string) IRoutes {
+	prefix := joinPaths(group.calculateAbsolutePath(relativePath), "/")
+	handler := WrapH(perf.AdminHandler(prefix))
+	pattern := joinPaths(relativePath, "/*name")
+	group.GET(pattern, handler)
+	group.POST(pattern, handler)
+	return group.returnObj()
 }
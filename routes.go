@@ -0,0 +1,80 @@
+package pudding
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// RouteInfo describes a registered route
+type RouteInfo struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	Color  string `json:"color,omitempty"`
+	// Handler 最后一个处理函数的名字
+	Handler string `json:"handler"`
+	// Middleware 处理函数之前的中间件, 按执行顺序排列
+	Middleware []string        `json:"middleware"`
+	Injections []InjectionInfo `json:"injections,omitempty"`
+	// Config 生效的MethodConfig, 合并了ServerConfig中的默认值
+	Config MethodConfig `json:"config"`
+}
+
+// Routes returns the registered routes in the registration order
+// 返回已注册的路由, 包括处理函数、中间件和生效的配置
+func (engine *Engine) Routes() []RouteInfo {
+	routes := make([]RouteInfo, 0, len(engine.routes))
+	for _, r := range engine.routes {
+		chain := engine.routeChain(r)
+		info := RouteInfo{
+			Method:     r.method,
+			Path:       r.path,
+			Color:      r.color,
+			Handler:    nameOfFunction(chain[len(chain)-1]),
+			Middleware: make([]string, 0, len(chain)-1),
+			Injections: engine.RouteInjections(r.method, r.path),
+		}
+		for _, h := range chain[:len(chain)-1] {
+			info.Middleware = append(info.Middleware, nameOfFunction(h))
+		}
+		engine.effectiveConfig(&info.Config, engine.methodConfig(r.path))
+		routes = append(routes, info)
+	}
+	return routes
+}
+
+// PrintRoutes writes the route table into w, one route per line
+func (engine *Engine) PrintRoutes(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "METHOD\tPATH\tCOLOR\tHANDLER\tMIDDLEWARE\tTIMEOUT")
+	for _, r := range engine.Routes() {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%s\n", r.Method, r.Path, r.Color, r.Handler, len(r.Middleware), time.Duration(r.Config.Timeout))
+	}
+	tw.Flush()
+}
+
+// debugPrintRoutes 调试模式下启动时打印路由表
+func (engine *Engine) debugPrintRoutes() {
+	engine.lock.RLock()
+	debug := engine.conf.Debug
+	engine.lock.RUnlock()
+	if !debug {
+		return
+	}
+	buf := &strings.Builder{}
+	engine.PrintRoutes(buf)
+	log.Printf("pudding: routes:\n%s", buf.String())
+}
+
+// DebugRoutes mounts the route table as JSON at relativePath under the group,
+// the middleware of the group is used to protect it
+// 在group下挂载路由表的调试接口, 通过group的中间件做权限控制
+func (group *RouterGroup) DebugRoutes(relativePath string) IRoutes {
+	engine := group.engine
+	return group.GET(relativePath, func(c *Context) {
+		c.JSON(engine.Routes(), nil)
+	})
+}
@@ -0,0 +1,130 @@
+package pudding
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bdjimmy/pudding/utils"
+)
+
+const _pkg = "github.com/bdjimmy/pudding."
+
+func routesGlobal(c *Context)  {}
+func routesGroup(c *Context)   {}
+func routesCanary(c *Context)  {}
+func routesInject(c *Context)  {}
+func routesHandler(c *Context) { c.String(http.StatusOK, c.Param("id")) }
+
+// routesEngine 注册一个参数路由和同一路径上的染色路由
+func routesEngine(debug bool) *Engine {
+	engine := NewServer(&ServerConfig{TimeOut: utils.Duration(time.Second), Debug: debug})
+	engine.UseFunc(routesGlobal)
+	engine.Inject("^/api/", routesInject)
+	api := engine.Group("/api", routesGroup)
+	api.GET("/user/:id", routesHandler)
+	api.Color("canary", routesCanary).GET("/user/:id", routesHandler)
+	engine.SetMethodConfig("/api/*", &MethodConfig{Timeout: utils.Duration(2 * time.Second)})
+	return engine
+}
+
+func TestRoutes(t *testing.T) {
+	engine := routesEngine(false)
+	injections := []InjectionInfo{{Pattern: "^/api/", Handlers: []string{_pkg + "routesInject"}}}
+	want := []RouteInfo{
+		{
+			Method:     http.MethodGet,
+			Path:       "/api/user/:id",
+			Handler:    _pkg + "routesHandler",
+			Middleware: []string{_pkg + "routesGlobal", _pkg + "(*Engine).guard-fm", _pkg + "routesGroup", _pkg + "routesInject"},
+			Injections: injections,
+		},
+		{
+			Method:     http.MethodGet,
+			Path:       "/api/user/:id",
+			Color:      "canary",
+			Handler:    _pkg + "routesHandler",
+			Middleware: []string{_pkg + "routesGlobal", _pkg + "(*Engine).guard-fm", _pkg + "routesGroup", _pkg + "routesCanary", _pkg + "routesInject"},
+			Injections: injections,
+		},
+	}
+	routes := engine.Routes()
+	if len(routes) != len(want) {
+		t.Fatalf("routes: %+v", routes)
+	}
+	for i, r := range routes {
+		if time.Duration(r.Config.Timeout) != 2*time.Second {
+			t.Errorf("route %d timeout: %v", i, time.Duration(r.Config.Timeout))
+		}
+		r.Config = MethodConfig{}
+		if !reflect.DeepEqual(r, want[i]) {
+			t.Errorf("route %d:\n got %+v\nwant %+v", i, r, want[i])
+		}
+	}
+
+	// 路由表中的路径是模板, 请求仍然按照参数匹配
+	req := httptest.NewRequest(http.MethodGet, "/api/user/42", nil)
+	req.Header.Set(_httpHeaderColor, "canary")
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	if w.Body.String() != "42" {
+		t.Errorf("serve: %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestPrintRoutes(t *testing.T) {
+	buf := &bytes.Buffer{}
+	routesEngine(false).PrintRoutes(buf)
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	want := [][]string{
+		{"METHOD", "PATH", "COLOR", "HANDLER", "MIDDLEWARE", "TIMEOUT"},
+		{"GET", "/api/user/:id", _pkg + "routesHandler", "4", "2s"},
+		{"GET", "/api/user/:id", "canary", _pkg + "routesHandler", "5", "2s"},
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("route table:\n%s", buf.String())
+	}
+	for i, line := range lines {
+		if fields := strings.Fields(line); !reflect.DeepEqual(fields, want[i]) {
+			t.Errorf("line %d: %q", i, line)
+		}
+	}
+}
+
+func TestDebugPrintRoutes(t *testing.T) {
+	buf := &bytes.Buffer{}
+	out := log.Writer()
+	log.SetOutput(buf)
+	defer log.SetOutput(out)
+	routesEngine(false).debugPrintRoutes()
+	if buf.Len() != 0 {
+		t.Errorf("debug disabled: %s", buf.String())
+	}
+	routesEngine(true).debugPrintRoutes()
+	if table := buf.String(); !strings.Contains(table, "pudding: routes:") || strings.Count(table, "/api/user/:id") != 2 {
+		t.Errorf("startup route table: %s", table)
+	}
+}
+
+func TestDebugRoutes(t *testing.T) {
+	engine := routesEngine(false)
+	engine.Group("/debug").DebugRoutes("/routes")
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/routes", nil))
+	var body struct {
+		Code int         `json:"code"`
+		Data []RouteInfo `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("%v %s", err, w.Body.String())
+	}
+	if body.Code != 0 || len(body.Data) != 3 || body.Data[1].Color != "canary" || body.Data[2].Path != "/debug/routes" {
+		t.Errorf("debug routes: %s", w.Body.String())
+	}
+}
@@ -2,31 +2,62 @@ package pudding
 
 import (
 	"context"
-	"github.com/bdjimmy/pudding/metadata"
+	"flag"
+	"fmt"
+	"github.com/bdjimmy/pudding/dsn"
+	"github.com/bdjimmy/pudding/ecode"
 	"github.com/bdjimmy/pudding/utils"
 	"github.com/pkg/errors"
 	"log"
 	"net"
 	"net/http"
-	"regexp"
+	"os"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 	"github.com/bdjimmy/pudding/middleware/perf"
+	"github.com/bdjimmy/pudding/rbac"
 )
 
 const (
 	defaultMaxMemory = 32 << 20 // 32 MB
+	// 没有设置 -http 和 HTTP 环境变量时的默认监听配置
+	defaultHTTPDSN = "tcp://0.0.0.0:8000/?timeout=1s"
 )
 
 var (
 	// Engine 实现了IRouter
 	_ IRouter = &Engine{}
+
+	// 通过 -http 参数或者 HTTP 环境变量设置的dsn
+	_httpDSN string
 )
 
 func init() {
+	addFlag(flag.CommandLine)
+}
 
+// addFlag 注册 -http 参数, 默认值取自 HTTP 环境变量
+func addFlag(fs *flag.FlagSet) {
+	v := os.Getenv("HTTP")
+	if v == "" {
+		v = defaultHTTPDSN
+	}
+	fs.StringVar(&_httpDSN, "http", v, "listen http dsn, or use HTTP env variable.")
+}
+
+// parseDSN 把dsn解析成ServerConfig, 例如 tcp://0.0.0.0:8000/?timeout=1s&readTimeout=2s
+func parseDSN(rawdsn string) *ServerConfig {
+	conf := new(ServerConfig)
+	d, err := dsn.Parse(rawdsn)
+	if err != nil {
+		panic(errors.Wrapf(err, "pudding: invalid dsn: %s", rawdsn))
+	}
+	if err = d.Bind(conf); err != nil {
+		panic(errors.Wrapf(err, "pudding: invalid dsn: %s", rawdsn))
+	}
+	return conf
 }
 
 // Handler responds to an HTTP request.
@@ -49,11 +80,42 @@ type ServerConfig struct {
 	TimeOut      utils.Duration `dsn:"timeout"`
 	ReadTimeOut  utils.Duration `dsn:"query.readTimeout"`
 	WriteTimeOut utils.Duration `dsn:"query.writeTimeout"`
+	// Drain 优雅退出前健康检查返回未就绪的时间, 等待负载均衡摘除流量
+	Drain utils.Duration `dsn:"query.drain"`
+	// ShutdownTimeOut 优雅退出时等待活动连接结束的最长时间
+	ShutdownTimeOut utils.Duration `dsn:"query.shutdownTimeout"`
+	// MaxBodyBytes 请求体的最大字节数, 0 不限制
+	MaxBodyBytes int64 `dsn:"query.maxBodyBytes"`
+	// MaxMultipartMemory 解析multipart表单时使用的最大内存, 超过的部分写入临时文件, 默认32MB
+	MaxMultipartMemory int64 `dsn:"query.maxMultipartMemory"`
+	// MaxFiles multipart表单中最多的文件个数, 0 不限制
+	MaxFiles int `dsn:"query.maxFiles"`
+	// Debug 调试模式, 启动时打印路由表
+	Debug bool `dsn:"query.debug"`
 }
 
-// MethodConfig is the pudding server's methods config model
+// MethodConfig is the pudding server's methods config model, the zero fields are not enforced.
+// It can be set in code by SetMethodConfig or loaded from a JSON file at runtime, e.g.
+//
+//	{"/user/:id": {"timeout": "500ms", "quota": 100, "maxConcurrency": 20, "auth": "jwt"}}
 type MethodConfig struct {
-	Timeout utils.Duration
+	// Timeout 超时时间, 0 使用ServerConfig中的配置
+	Timeout utils.Duration `json:"timeout,omitempty"`
+	// Slow 慢请求阈值, 访问日志中会标记超过阈值的请求
+	Slow utils.Duration `json:"slow,omitempty"`
+	// 请求体的限制, 0 使用ServerConfig中的配置
+	MaxBodyBytes       int64 `json:"maxBodyBytes,omitempty"`
+	MaxMultipartMemory int64 `json:"maxMultipartMemory,omitempty"`
+	MaxFiles           int   `json:"maxFiles,omitempty"`
+	// Quota 每秒允许的请求数, Burst 允许的突发请求数, 默认为Quota向上取整, 超过时返回ecode.LimitExceed
+	Quota float64 `json:"quota,omitempty"`
+	Burst int     `json:"burst,omitempty"`
+	// MaxConcurrency 最大并发请求数, 超过时返回ecode.LimitExceed
+	MaxConcurrency int64 `json:"maxConcurrency,omitempty"`
+	// Auth 需要的鉴权方式, 由SetAuthenticator注册的处理函数校验, 未注册的方式拒绝所有请求
+	Auth string `json:"auth,omitempty"`
+	// CacheControl 响应的Cache-Control头, 例如 no-store 或者 public, max-age=60
+	CacheControl string `json:"cacheControl,omitempty"`
 }
 
 // Engine
@@ -67,44 +129,88 @@ type Engine struct {
 	// 监听的地址
 	address string
 
-	// http mux router
-	// http 多路复用路由
-	mux *http.ServeMux
+	// trees 每个http方法对应一棵基数树路由
+	trees methodTrees
+	// colorTrees 染色路由, 带有对应染色标记的请求优先匹配
+	colorTrees map[string]methodTrees
 	// store *http.Server
 	// 原子保留http的server指针
 	server atomic.Value
 
 	// metastore is the path as key and the metadata of this path as value
+	metaLock  sync.RWMutex
 	metastore map[string]map[string]interface{}
+	// requires 路由和group声明的角色和权限, key为染色标记、方法和路由模板, 和metastore共用metaLock
+	requires map[string]rbac.Requirement
 
 	// RWMutex 保护methodConfigs变量
-	pcLock        sync.RWMutex
-	methodConfigs map[string]*MethodConfig
+	// methodConfigs 是生效的配置, 由代码设置的staticConfigs和运行时加载的loadedConfigs合并, 后者优先
+	pcLock         sync.RWMutex
+	methodConfigs  map[string]*MethodConfig
+	prefixConfigs  []prefixConfig
+	staticConfigs  map[string]*MethodConfig
+	loadedConfigs  map[string]*MethodConfig
+	configWatchers []MethodConfigWatcher
+
+	// 路由的限流和并发状态, 鉴权方式对应的处理函数, 以及请求被限流时的回调
+	guardLock      sync.RWMutex
+	guards         map[guardKey]*routeGuard
+	authenticators map[string]HandlerFunc
+	limitHooks     []LimitHook
 
 	// 保留通过正则注册公共的中间件
 	injections []injection
+	// 已注册的路由, 注册新的injection后重新生成处理链
+	routes []*routeEntry
+	// 路由对应的预检请求处理链, 没有注册OPTIONS时用于应答CORS预检请求
+	preflights map[string][]HandlerFunc
+
+	// 未匹配到路由、方法不匹配时的处理函数, all* 为合并全局中间件后的处理链
+	noRoute     []HandlerFunc
+	noMethod    []HandlerFunc
+	allNoRoute  []HandlerFunc
+	allNoMethod []HandlerFunc
+	allOptions  []HandlerFunc
+
+	// pprof配置, 启动服务时生效
+	perfConf *perf.Config
+
+	// 复用请求的Context, 减少每个请求的内存分配
+	pool sync.Pool
+
+	// 生命周期: 退出中标记、启动和退出的钩子、服务运行中的错误
+	draining   int32
+	hookLock   sync.Mutex
+	onStart    []Hook
+	onShutdown []Hook
+	errCh      chan error
 }
 
-// injection
-type injection struct {
-	pattern  *regexp.Regexp
-	handlers []HandlerFunc
-}
-
-// Start listen and serve pudding engine by given DSN
+// Start listen and serve pudding engine by given DSN, the OnStart hooks are called before listening
+// The server errors are sent to the channel returned by Errors instead of panicking
 func (engine *Engine) Start() error {
+	engine.lock.RLock()
 	conf := engine.conf
+	engine.lock.RUnlock()
+	if err := engine.runHooks(context.Background(), true); err != nil {
+		return errors.WithMessage(err, "pudding: start hook")
+	}
 	l, err := net.Listen(conf.NewWork, conf.Address)
 	if err != nil {
-		errors.Wrapf(err, "pudding: listen tcp: %s", conf.Address)
-		return err
+		return errors.Wrapf(err, "pudding: listen tcp: %s", conf.Address)
 	}
 
 	log.Printf("pudding: start http listen addr: %s", conf.Address)
+	engine.debugPrintRoutes()
+	engine.startPerf()
 	server := &http.Server{
+		Handler:           engine,
 		ReadHeaderTimeout: time.Duration(conf.ReadTimeOut),
 		WriteTimeout:      time.Duration(conf.WriteTimeOut),
 	}
+	// 在启动协程之前保存server, Start返回后立即ShutDown也能关闭监听
+	engine.server.Store(server)
+	atomic.StoreInt32(&engine.draining, 0)
 	// 启动一个协程进行管理
 	go func() {
 		if err := engine.RunServer(server, l); err != nil {
@@ -113,7 +219,7 @@ func (engine *Engine) Start() error {
 				log.Print("pudding: server closed")
 				return
 			}
-			panic(errors.Wrapf(err, "pudding: engine.ListenServer(%+v, %+v)", server, l))
+			engine.reportError(errors.WithMessage(err, "pudding: engine.RunServer"))
 		}
 	}()
 
@@ -122,39 +228,25 @@ func (engine *Engine) Start() error {
 
 // New returns a new blank Engine instance without any middleware attached
 func New() *Engine {
-	engine := &Engine{
-		RouterGroup: RouterGroup{
-			Handlers: nil,
-			basePath: "/",
-			root:     true,
-		},
-		address: "",
-		conf: &ServerConfig{
-			TimeOut: utils.Duration(time.Second),
-		},
-		mux:           http.NewServeMux(),
-		metastore:     make(map[string]map[string]interface{}),
-		methodConfigs: make(map[string]*MethodConfig),
-		injections:    make([]injection, 0),
-	}
-	engine.RouterGroup.engine = engine
-	// Note add prometheus monitor location
-	// Note start pprof
-	perf.StartPerf()
-	return engine
+	return newEngine(&ServerConfig{
+		TimeOut: utils.Duration(time.Second),
+	})
 }
 
 // NewServer returns a new blank Engine instance without any middleware attached
+// If conf is nil, the config is parsed from -http flag or HTTP env
 func NewServer(conf *ServerConfig) *Engine {
-	//if conf == nil{
-	//	if !flag.Parsed() {
-	//		fmt.Fprintf(os.Stderr, "pudding: please call flag.Parse() before Init warden server, some configure may not effect \n")
-	//	}
-	//	conf = parseDSN(_httpDSN)
-	//}else{
-	//	fmt.Fprintf(os.Stderr, "pudding: config will deprecated, argument will be ignored. please use -http flag or HTTP env to configure http server \n ")
-	//}
+	if conf == nil {
+		if !flag.Parsed() {
+			fmt.Fprintf(os.Stderr, "pudding: please call flag.Parse() before Init pudding server, some configure may not effect \n")
+		}
+		conf = parseDSN(_httpDSN)
+	}
+	return newEngine(conf)
+}
 
+// newEngine New和NewServer共用的初始化, 配置经过SetConfig校验并填充默认值
+func newEngine(conf *ServerConfig) *Engine {
 	engine := &Engine{
 		RouterGroup: RouterGroup{
 			Handlers: nil,
@@ -162,17 +254,22 @@ func NewServer(conf *ServerConfig) *Engine {
 			root:     true,
 		},
 		address:       "",
-		mux:           http.NewServeMux(),
+		trees:         make(methodTrees, 0, 9),
 		metastore:     make(map[string]map[string]interface{}),
 		methodConfigs: make(map[string]*MethodConfig),
+		staticConfigs: make(map[string]*MethodConfig),
+		guards:        make(map[guardKey]*routeGuard),
+		injections:    make([]injection, 0),
+		errCh:         make(chan error, 1),
 	}
 	if err := engine.SetConfig(conf); err != nil {
 		panic(err)
 	}
 	engine.RouterGroup.engine = engine
-	// Note add prometheus monitor location
-	// Note start pprof
-	perf.StartPerf()
+	engine.pool.New = func() interface{} {
+		return engine.allocateContext()
+	}
+	engine.rebuildHandlers()
 	return engine
 }
 
@@ -180,25 +277,25 @@ func NewServer(conf *ServerConfig) *Engine {
 func DefaultServer(conf *ServerConfig) *Engine {
 	engine := NewServer(conf)
 	// 默认使用某些中间件
-	//engine.Use()
+	engine.UseFunc(Recovery())
+	engine.Metrics("/metrics")
 	return engine
 }
 
 func Default() *Engine {
 	engine := New()
-	//engine.Use()
+	engine.UseFunc(Recovery())
+	engine.Metrics("/metrics")
 	return engine
 }
 
-// SetMethodConfig is used to set config on specified path
-func (engine *Engine) SetMethodConfig(path string, mc *MethodConfig) {
-	engine.pcLock.Lock()
-	engine.methodConfigs[path] = mc
-	engine.pcLock.Unlock()
-}
-
 // 添加请求路由, handlers 为中间件和具体的请求处理函数
 func (engine *Engine) addRoute(method, path string, handlers ...HandlerFunc) {
+	engine.addColorRoute("", method, path, handlers...)
+}
+
+// addColorRoute 注册染色路由, color为空时注册到默认的路由树
+func (engine *Engine) addColorRoute(color, method, path string, handlers ...HandlerFunc) {
 	if path[0] != '/' {
 		panic("pudding: path must begin with '/' ")
 	}
@@ -209,29 +306,218 @@ func (engine *Engine) addRoute(method, path string, handlers ...HandlerFunc) {
 		panic("pudding: there must be at least one handler")
 	}
 	// 初始化path的meta数据
+	engine.metaLock.Lock()
 	if _, ok := engine.metastore[path]; !ok {
 		engine.metastore[path] = make(map[string]interface{})
 	}
-	// 向多路复用器中注册函数, 每个请求都会创建一个context
-	engine.mux.HandleFunc(path, func(w http.ResponseWriter, req *http.Request) {
-		c := &Context{
-			Context:  nil,
-			engine:   engine,
-			index:    -1,
-			handlers: nil,
-			Keys:     nil,
-			method:   "",
-			Error:    nil,
+	engine.metaLock.Unlock()
+	// 每个http方法对应一棵路由树, 同一个path的不同方法拥有各自的处理链
+	trees := engine.trees
+	if color != "" {
+		if engine.colorTrees == nil {
+			engine.colorTrees = make(map[string]methodTrees)
+		}
+		trees = engine.colorTrees[color]
+	}
+	root := trees.get(method)
+	if root == nil {
+		root = new(node)
+		trees = append(trees, methodTree{method: method, root: root})
+	}
+	root.addRoute(path, handlers)
+	if color != "" {
+		engine.colorTrees[color] = trees
+	} else {
+		engine.trees = trees
+	}
+}
+
+// ServeHTTP conforms to the http.Handler interface, every request creates a context
+// 实现http.Handler接口, 每个请求都会创建一个context
+func (engine *Engine) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	// Context从池中获取, 处理完之后放回, 处理函数返回后不能再使用c, 后台任务需要使用c.Copy()
+	c := engine.pool.Get().(*Context)
+	c.writermem.reset(w)
+	c.Request = req
+	c.reset()
+
+	engine.handleContext(c)
+
+	engine.pool.Put(c)
+}
+
+// allocateContext 创建池中的Context
+func (engine *Engine) allocateContext() *Context {
+	return &Context{engine: engine}
+}
+
+// prepareHandler dispatches the request by method and path before any handler runs
+// 在执行任何处理函数之前, 根据请求的方法和路径选择处理链:
+// 匹配的路由 -> 自动应答的OPTIONS -> 405 Method Not Allowed -> 404 Not Found,
+// 自动应答的CORS预检请求会先执行对应路由的CORS中间件
+func (engine *Engine) prepareHandler(c *Context) {
+	method := c.Request.Method
+	rPath := c.Request.URL.Path
+	c.method = method
+	if value, routeColor := engine.lookup(c.Request, method, rPath, c.Params); value.handlers != nil {
+		c.handlers = value.handlers
+		c.Params = value.params
+		c.RoutePath = value.fullPath
+		c.routeColor = routeColor
+		return
+	}
+	if allow := engine.allowed(c.Request, rPath, method); allow != "" {
+		c.Writer.Header().Set("Allow", allow)
+		if method == http.MethodOptions {
+			c.handlers = engine.allOptions
+			engine.preparePreflight(c)
+			return
 		}
+		c.handlers = engine.allNoMethod
+		return
+	}
+	c.handlers = engine.allNoRoute
+}
 
-		c.Request = req
-		c.Writer = w
+// lookup 查找匹配的路由, 染色请求优先匹配对应的染色路由, 未注册时回落到默认路由,
+// 直接读取请求头, 避免为没有染色的请求构建metadata
+func (engine *Engine) lookup(req *http.Request, method, path string, po Params) (value nodeValue, routeColor string) {
+	if len(engine.colorTrees) > 0 {
+		routeColor = color(req)
+		if root := engine.colorTrees[routeColor].get(method); root != nil {
+			if value = root.getValue(path, po); value.handlers != nil {
+				return
+			}
+		}
+	}
+	routeColor = ""
+	if root := engine.trees.get(method); root != nil {
+		value = root.getValue(path, po)
+	}
+	return
+}
+
+// preparePreflight CORS预检请求使用Access-Control-Request-Method对应路由的CORS中间件,
+// CORS中间件可以应答预检请求, 没有中间件应答时返回自动应答的结果
+func (engine *Engine) preparePreflight(c *Context) {
+	reqMethod := c.Request.Header.Get("Access-Control-Request-Method")
+	if reqMethod == "" {
+		return
+	}
+	value, routeColor := engine.lookup(c.Request, reqMethod, c.Request.URL.Path, c.Params)
+	if value.handlers == nil {
+		return
+	}
+	if handlers, ok := engine.preflights[preflightKey(routeColor, reqMethod, value.fullPath)]; ok {
 		c.handlers = handlers
-		c.method = method
+		c.Params = value.params
+		c.RoutePath = value.fullPath
+		c.routeColor = routeColor
+	}
+}
 
-		// 注册自己的处理函数
-		engine.handleContext(c)
-	})
+// allowed returns the methods registered on the given path, joined as the Allow header value,
+// the color routes matching the color of the request are included
+// 返回path上已注册的方法, 包括请求染色标记对应的染色路由, OPTIONS 总是会被自动应答
+func (engine *Engine) allowed(req *http.Request, path, reqMethod string) string {
+	allow := make([]string, 0, len(engine.trees)+1)
+	options := false
+	add := func(trees methodTrees) {
+		for _, tree := range trees {
+			if tree.method == reqMethod || hasMethod(allow, tree.method) {
+				continue
+			}
+			if value := tree.root.getValue(path, nil); value.handlers != nil {
+				allow = append(allow, tree.method)
+				options = options || tree.method == http.MethodOptions
+			}
+		}
+	}
+	if len(engine.colorTrees) > 0 {
+		add(engine.colorTrees[color(req)])
+	}
+	add(engine.trees)
+	if len(allow) == 0 {
+		return ""
+	}
+	if !options {
+		allow = append(allow, http.MethodOptions)
+	}
+	return strings.Join(allow, ", ")
+}
+
+func hasMethod(methods []string, method string) bool {
+	for _, m := range methods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// NoRoute adds handlers for NoRoute. It return a 404 code by default
+func (engine *Engine) NoRoute(handlers ...HandlerFunc) {
+	engine.noRoute = handlers
+	engine.rebuildHandlers()
+}
+
+// NoMethod sets the handlers called when the path matches but the method doesn't.
+// It return a 405 code with the Allow header by default
+func (engine *Engine) NoMethod(handlers ...HandlerFunc) {
+	engine.noMethod = handlers
+	engine.rebuildHandlers()
+}
+
+// rebuildHandlers 全局中间件或者NoRoute/NoMethod变化后, 重新合并处理链
+func (engine *Engine) rebuildHandlers() {
+	noRoute, noMethod := engine.noRoute, engine.noMethod
+	if len(noRoute) == 0 {
+		noRoute = []HandlerFunc{defaultNoRoute}
+	}
+	if len(noMethod) == 0 {
+		noMethod = []HandlerFunc{defaultNoMethod}
+	}
+	engine.allNoRoute = engine.combineHandlers(noRoute)
+	engine.allNoMethod = engine.combineHandlers(noMethod)
+	engine.allOptions = engine.combineHandlers([]HandlerFunc{defaultOptions})
+}
+
+func defaultNoRoute(c *Context) {
+	c.Error = _errNothingFound
+	writeStatusText(c.Writer, http.StatusNotFound, _notFoundBody)
+}
+
+func defaultNoMethod(c *Context) {
+	c.Error = _errMethodNotAllowed
+	writeStatusText(c.Writer, http.StatusMethodNotAllowed, _methodNotAllowedBody)
+}
+
+// 预先转换成error, 避免每个请求分配内存
+var (
+	_errNothingFound     error = ecode.NothingFound
+	_errMethodNotAllowed error = ecode.MethodNotAllowed
+)
+
+var (
+	_plainContentType     = []string{"text/plain; charset=utf-8"}
+	_nosniff              = []string{"nosniff"}
+	_notFoundBody         = []byte(http.StatusText(http.StatusNotFound) + "\n")
+	_methodNotAllowedBody = []byte(http.StatusText(http.StatusMethodNotAllowed) + "\n")
+)
+
+// writeStatusText 和http.Error的响应相同, 复用响应头的值和响应体, 避免每个请求分配内存
+func writeStatusText(w http.ResponseWriter, code int, body []byte) {
+	header := w.Header()
+	delete(header, "Content-Length")
+	header["Content-Type"] = _plainContentType
+	header["X-Content-Type-Options"] = _nosniff
+	w.WriteHeader(code)
+	w.Write(body)
+}
+
+// defaultOptions 自动应答OPTIONS请求, Allow 头已经在分发时设置
+func defaultOptions(c *Context) {
+	c.AbortWithStatus(http.StatusNoContent)
 }
 
 func (engine *Engine) SetConfig(conf *ServerConfig) (err error) {
@@ -249,61 +535,44 @@ func (engine *Engine) SetConfig(conf *ServerConfig) (err error) {
 	return
 }
 
-func (engine *Engine) methodConfig(path string) *MethodConfig {
-	engine.pcLock.RLock()
-	mc := engine.methodConfigs[path]
-	engine.pcLock.RUnlock()
-	return mc
-}
-
 func (engine *Engine) handleContext(c *Context) {
-	var cancel func()
 	req := c.Request
-	// 解析http请求的数据, switch的用法
-	switch {
-	case strings.Contains(req.Header.Get("Content-Type"), "multipart/form-data"):
-		c.Request.ParseMultipartForm(defaultMaxMemory)
-	default:
-		c.Request.ParseForm()
-	}
+	// 请求的表单在处理函数读取参数时才解析, 参见 c.ParseForm
+
+	// 先根据方法和路径选择处理链, MethodConfig按照匹配到的路由模板获取
+	engine.prepareHandler(c)
+	c.methodConfig = engine.methodConfig(c.RoutePath)
+	engine.effectiveConfig(&c.effectiveConfig, c.methodConfig)
+	mc := &c.effectiveConfig
 
 	// get derived timeout from http request header, compare with the engine configured, and use the minimum one
 	// 从http头部获取请求的超时时间，并和配置中的超时时间比对，最终设置小的那个超时时间
-	engine.lock.RLock()
-	tm := time.Duration(engine.conf.TimeOut)
-	engine.lock.RUnlock()
-	// the method config is preferred
-	// engine.conf.Timeout < engine.methodConfig.timeout, 每个方法也可以自定义超时时间
-	if pc := engine.methodConfig(req.URL.Path); pc != nil {
-		tm = time.Duration(pc.Timeout)
-	}
+	tm := time.Duration(mc.Timeout)
 	// 从http的请求头中获取客户端超时时间， 和服务端配置的超时时间比对
 	if ctm := timeout(req); ctm > 0 && tm > ctm {
 		tm = ctm
 	}
-	// 设置metadata
-	md := metadata.MD{
-		metadata.RemoteIP:   remoteIP(req),
-		metadata.RemotePort: remotePort(req),
-		metadata.Caller:     caller(req),
-		metadata.Mirror:     mirror(req),
-	}
-	ctx := metadata.NewContext(context.Background(), md)
-	if tm > 0 {
-		c.Context, cancel = context.WithTimeout(ctx, tm)
-	} else {
-		c.Context, cancel = context.WithCancel(ctx)
+	// 限制请求体的大小, 超过限制时读取请求体会返回错误, 解析表单和绑定参数时返回413
+	if mc.MaxBodyBytes > 0 && req.Body != nil && req.Body != http.NoBody {
+		req.Body = http.MaxBytesReader(c.Writer, req.Body, mc.MaxBodyBytes)
 	}
+	// 设置metadata, 第一次读取时才从请求头构建
+	c.mdCtx.Reset(context.Background(), newMD, req)
+	c.deadline.reset(&c.mdCtx, tm)
+	c.Context = &c.deadline
 	// 这个地方需要注意， 所有中间件执行完会调用取消函数
 	// 所以， 如果后台执行一定要调用NewContext或者FromContext，否则后台任务会被自动取消
-	defer cancel()
+	defer c.deadline.cancel(context.Canceled)
+	// 按路由模板统计请求数、耗时和业务码
+	method, path, start := metricsBegin(c)
+	defer metricsEnd(c, method, path, start)
 	c.Next()
 }
 
 // Router return a http.Handler for using http.ListenAndServe() directly
 // 从engine中返回http.Handler给http.ListenAndServe直接使用
 func (engine *Engine) Router() http.Handler {
-	return engine.mux
+	return engine
 }
 
 // Server is used to load stored http server
@@ -316,40 +585,79 @@ func (engine *Engine) Server() *http.Server {
 	return s
 }
 
-// Shutdown the http server without interrupting active connections
-// 关闭Server, 不中断活动连接
+// Shutdown the http server without interrupting active connections,
+// the health check reports not-ready and the OnShutdown hooks are called after the server stopped
+// 关闭Server, 不中断活动连接, ctx的deadline是等待活动连接结束的最长时间
 func (engine *Engine) ShutDown(ctx context.Context) error {
 	server := engine.Server()
 	if server == nil {
 		return errors.New("pudding: no server")
 	}
-	return errors.WithStack(server.Shutdown(ctx))
+	atomic.StoreInt32(&engine.draining, 1)
+	err := errors.WithStack(server.Shutdown(ctx))
+	if herr := engine.runHooks(ctx, false); herr != nil && err == nil {
+		err = errors.WithMessage(herr, "pudding: shutdown hook")
+	}
+	return err
 }
 
-//// UseFunc attaches a global middleware to the router.
-//// ie. the middleware attached though UseFunc() will be include in the handlers chain for every single request
-//// Even 404, 405, static files...
-//// For example, this is the right place for a logger or error management middleware
-//func (engine *Engine) UseFunc(middleware ...HandlerFunc) IRoutes {
-//	engine.RouterGroup.UseFunc(middleware...)
-//	return engine
-//}
-//
-//// Use
-//func (engine *Engine) Use(middleware ...Handler) IRoutes {
-//	engine.RouterGroup.Use(middleware...)
-//	return engine
-//}
+// UseFunc attaches a global middleware to the router.
+// ie. the middleware attached though UseFunc() will be include in the handlers chain for every single request
+// Even 404, 405, static files...
+// For example, this is the right place for a logger or error management middleware
+func (engine *Engine) UseFunc(middleware ...HandlerFunc) IRoutes {
+	engine.RouterGroup.UseFunc(middleware...)
+	engine.rebuildHandlers()
+	return engine
+}
+
+// Use attaches a global middleware to the router.
+func (engine *Engine) Use(middleware ...Handler) IRoutes {
+	engine.RouterGroup.Use(middleware...)
+	engine.rebuildHandlers()
+	return engine
+}
+
+// SetPerf sets the standalone pprof config, it takes effect when the engine starts
+// The default config is from -http.perf flag or HTTP_PERF env and listens on 127.0.0.1:2333 if neither is set,
+// use RouterGroup.Perf to mount pprof onto the engine behind the middleware
+func (engine *Engine) SetPerf(conf *perf.Config) {
+	engine.lock.Lock()
+	engine.perfConf = conf
+	engine.lock.Unlock()
+}
+
+// startPerf 启动独立监听的pprof, 失败只记录日志, 不影响服务
+func (engine *Engine) startPerf() {
+	engine.lock.RLock()
+	conf := engine.perfConf
+	engine.lock.RUnlock()
+	if err := perf.Start(conf); err != nil {
+		log.Printf("pudding: start perf error(%+v)", err)
+	}
+}
 
 // Ping is used to set the general HTTP ping handler
+// It responds 503 without calling handler when the engine is draining
 func (engine *Engine) Ping(handler HandlerFunc) {
-	engine.GET("/monitor/ping", handler)
+	engine.GET("/monitor/ping", func(c *Context) {
+		if !engine.Ready() {
+			c.AbortWithStatus(http.StatusServiceUnavailable)
+			return
+		}
+		handler(c)
+	})
 }
 
-// Register is used to export metadata to discovery
+// Register is used to export metadata to discovery, it mounts /register and /metadata,
+// the discovery document of the routes is served from /register if handler is nil
 // 返回已经注册的方法，用于服务发现
 func (engine *Engine) Register(handler HandlerFunc) {
+	if handler == nil {
+		handler = engine.register()
+	}
 	engine.GET("/register", handler)
+	engine.GET("/metadata", engine.metadata())
 }
 
 // Run attaches the router to a http.Server and starts listening and serving HTTP requests
@@ -358,9 +666,11 @@ func (engine *Engine) Register(handler HandlerFunc) {
 // 启动http服务，并且设置路由，调用者会被阻塞
 func (engine *Engine) Run(addr ...string) (err error) {
 	address := resolveAddress(addr)
-	server := http.Server{
+	engine.debugPrintRoutes()
+	engine.startPerf()
+	server := &http.Server{
 		Addr:    address,
-		Handler: engine.mux,
+		Handler: engine,
 	}
 	engine.server.Store(server)
 	if err = server.ListenAndServe(); err != nil {
@@ -371,10 +681,10 @@ func (engine *Engine) Run(addr ...string) (err error) {
 
 // RunServer will serve and start listening HTTP requests by given server and listener
 func (engine *Engine) RunServer(server *http.Server, l net.Listener) (err error) {
-	server.Handler = engine.mux
+	server.Handler = engine
 	engine.server.Store(server)
 	if err = server.Serve(l); err != nil {
-		err = errors.Wrapf(err, "listen server: %+v/%+v", server, l)
+		err = errors.Wrapf(err, "listen server: %s", l.Addr())
 		return
 	}
 	return
@@ -382,14 +692,12 @@ func (engine *Engine) RunServer(server *http.Server, l net.Listener) (err error)
 
 func (engine *Engine) metadata() HandlerFunc {
 	return func(c *Context) {
-		//c.JSON(engine.metastore, nil)
+		engine.metaLock.RLock()
+		store := make(map[string]map[string]interface{}, len(engine.metastore))
+		for path, meta := range engine.metastore {
+			store[path] = copyMeta(meta)
+		}
+		engine.metaLock.RUnlock()
+		c.JSON(store, nil)
 	}
 }
-
-// Inject 正则注册中间件
-func (engine *Engine) Inject(pattern string, handlers ...HandlerFunc) {
-	engine.injections = append(engine.injections, injection{
-		pattern:  regexp.MustCompile(pattern),
-		handlers: handlers,
-	})
-}
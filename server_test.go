@@ -0,0 +1,185 @@
+package pudding
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// benchWriter 丢弃响应的ResponseWriter, 避免把httptest.ResponseRecorder的分配算进去
+type benchWriter struct {
+	header http.Header
+}
+
+func (w *benchWriter) Header() http.Header         { return w.header }
+func (w *benchWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (w *benchWriter) WriteHeader(int)             {}
+
+func TestColorRouteAllowed(t *testing.T) {
+	engine := New()
+	engine.GET("/both", func(c *Context) {})
+	canary := engine.Color("canary")
+	canary.POST("/canary", func(c *Context) {})
+	canary.PUT("/both", func(c *Context) {})
+	cases := []struct {
+		method, path, color string
+		code                int
+		allow               string
+	}{
+		{http.MethodGet, "/canary", "canary", http.StatusMethodNotAllowed, "POST, OPTIONS"},
+		{http.MethodOptions, "/canary", "canary", http.StatusNoContent, "POST, OPTIONS"},
+		{http.MethodGet, "/canary", "", http.StatusNotFound, ""},
+		{http.MethodDelete, "/both", "canary", http.StatusMethodNotAllowed, "PUT, GET, OPTIONS"},
+		{http.MethodDelete, "/both", "", http.StatusMethodNotAllowed, "GET, OPTIONS"},
+	}
+	for _, cs := range cases {
+		req := httptest.NewRequest(cs.method, cs.path, nil)
+		if cs.color != "" {
+			req.Header.Set(_httpHeaderColor, cs.color)
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		if w.Code != cs.code || w.Header().Get("Allow") != cs.allow {
+			t.Errorf("%s %s color(%s): %d allow(%s)", cs.method, cs.path, cs.color, w.Code, w.Header().Get("Allow"))
+		}
+	}
+}
+
+func TestMethodNotAllowed(t *testing.T) {
+	engine := New()
+	h := func(c *Context) { c.String(http.StatusOK, c.Request.Method) }
+	engine.GET("/user/:id", h)
+	engine.POST("/user/:id", h)
+	engine.handle(http.MethodOptions, "/explicit", h)
+	engine.PUT("/explicit", h)
+	cases := []struct {
+		method, path string
+		code         int
+		allow        string
+	}{
+		{http.MethodDelete, "/user/1", http.StatusMethodNotAllowed, "GET, POST, OPTIONS"},
+		{http.MethodPatch, "/user/1", http.StatusMethodNotAllowed, "GET, POST, OPTIONS"},
+		// 注册了OPTIONS时不会重复
+		{http.MethodGet, "/explicit", http.StatusMethodNotAllowed, "OPTIONS, PUT"},
+		{http.MethodDelete, "/missing", http.StatusNotFound, ""},
+		{http.MethodGet, "/user/1", http.StatusOK, ""},
+	}
+	for _, cs := range cases {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(cs.method, cs.path, nil))
+		if w.Code != cs.code || w.Header().Get("Allow") != cs.allow {
+			t.Errorf("%s %s: %d allow(%s)", cs.method, cs.path, w.Code, w.Header().Get("Allow"))
+		}
+	}
+
+	// 自定义的NoMethod处理函数同样带有Allow头
+	engine.NoMethod(func(c *Context) { c.String(http.StatusMethodNotAllowed, "custom") })
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/user/1", nil))
+	if w.Code != http.StatusMethodNotAllowed || w.Body.String() != "custom" || w.Header().Get("Allow") != "GET, POST, OPTIONS" {
+		t.Errorf("custom NoMethod: %d %s allow(%s)", w.Code, w.Body.String(), w.Header().Get("Allow"))
+	}
+}
+
+func TestAutoOptions(t *testing.T) {
+	engine := New()
+	h := func(c *Context) { c.String(http.StatusOK, "handler") }
+	engine.GET("/user/:id", h)
+	engine.DELETE("/user/:id", h)
+	engine.handle(http.MethodOptions, "/explicit", h)
+	cases := []struct {
+		path  string
+		code  int
+		allow string
+		body  string
+	}{
+		{"/user/1", http.StatusNoContent, "GET, DELETE, OPTIONS", ""},
+		// 注册的OPTIONS路由优先于自动应答
+		{"/explicit", http.StatusOK, "", "handler"},
+		{"/missing", http.StatusNotFound, "", "Not Found\n"},
+	}
+	for _, cs := range cases {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodOptions, cs.path, nil))
+		if w.Code != cs.code || w.Header().Get("Allow") != cs.allow || w.Body.String() != cs.body {
+			t.Errorf("OPTIONS %s: %d allow(%s) %q", cs.path, w.Code, w.Header().Get("Allow"), w.Body.String())
+		}
+	}
+}
+
+func TestMethodMismatchSkipsMiddleware(t *testing.T) {
+	engine := New()
+	var global, group, handler int
+	engine.UseFunc(func(c *Context) { global++ })
+	api := engine.Group("/api", func(c *Context) { group++ })
+	api.GET("/user", func(c *Context) { handler++ })
+	cases := []struct {
+		method                 string
+		code                   int
+		global, group, handler int
+	}{
+		{http.MethodPost, http.StatusMethodNotAllowed, 1, 0, 0},
+		{http.MethodOptions, http.StatusNoContent, 1, 0, 0},
+		{http.MethodGet, http.StatusOK, 1, 1, 1},
+	}
+	for _, cs := range cases {
+		global, group, handler = 0, 0, 0
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(cs.method, "/api/user", nil))
+		// 全局中间件对所有请求生效, group和路由的处理函数只在方法匹配时执行
+		if w.Code != cs.code || global != cs.global || group != cs.group || handler != cs.handler {
+			t.Errorf("%s: %d global(%d) group(%d) handler(%d)", cs.method, w.Code, global, group, handler)
+		}
+	}
+}
+
+func TestNewConfig(t *testing.T) {
+	cases := []struct {
+		name    string
+		engine  *Engine
+		network string
+		timeout time.Duration
+	}{
+		{"New", New(), "tcp", time.Second},
+		{"NewServer", NewServer(parseDSN("unix:///tmp/pudding.sock?timeout=2s")), "unix", 2 * time.Second},
+		{"NewServer default network", NewServer(&ServerConfig{Address: ":8000"}), "tcp", 0},
+	}
+	for _, cs := range cases {
+		conf := cs.engine.conf
+		if conf.NewWork != cs.network || time.Duration(conf.TimeOut) != cs.timeout {
+			t.Errorf("%s: network(%s) timeout(%v)", cs.name, conf.NewWork, time.Duration(conf.TimeOut))
+		}
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("NewServer with negative timeout: expected panic")
+		}
+	}()
+	NewServer(&ServerConfig{TimeOut: -1})
+}
+
+func BenchmarkServeHTTP(b *testing.B) {
+	engine := New()
+	engine.GET("/ping", func(c *Context) {})
+	engine.GET("/user/:id", func(c *Context) { _ = c.Param("id") })
+	cases := []struct {
+		name string
+		path string
+	}{
+		{"static", "/ping"},
+		{"param", "/user/42"},
+		{"notfound", "/missing"},
+	}
+	for _, cs := range cases {
+		b.Run(cs.name, func(b *testing.B) {
+			req := httptest.NewRequest(http.MethodGet, cs.path, nil)
+			w := &benchWriter{header: make(http.Header)}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				engine.ServeHTTP(w, req)
+			}
+		})
+	}
+}
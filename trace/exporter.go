@@ -0,0 +1,89 @@
+package trace
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Exporter exports the finished spans, e.g. to a collector
+type Exporter interface {
+	Export(s *SpanData) error
+}
+
+// MemoryExporter keeps the finished spans in memory, it's used by tests
+type MemoryExporter struct {
+	mu    sync.Mutex
+	spans []*SpanData
+}
+
+// NewMemoryExporter new a in-memory exporter
+func NewMemoryExporter() *MemoryExporter {
+	return &MemoryExporter{}
+}
+
+// Export implements Exporter
+func (e *MemoryExporter) Export(s *SpanData) error {
+	e.mu.Lock()
+	e.spans = append(e.spans, s)
+	e.mu.Unlock()
+	return nil
+}
+
+// Spans returns the exported spans in finishing order
+func (e *MemoryExporter) Spans() []*SpanData {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]*SpanData(nil), e.spans...)
+}
+
+// Reset drops the exported spans
+func (e *MemoryExporter) Reset() {
+	e.mu.Lock()
+	e.spans = nil
+	e.mu.Unlock()
+}
+
+// writerExporter 每个span输出一行JSON
+type writerExporter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewWriterExporter returns an exporter writing one JSON line per span into w, e.g. os.Stdout
+func NewWriterExporter(w io.Writer) Exporter {
+	return &writerExporter{w: w}
+}
+
+// Export implements Exporter
+func (e *writerExporter) Export(s *SpanData) error {
+	bs, err := json.Marshal(&struct {
+		*SpanData
+		TraceID  string  `json:"trace_id"`
+		SpanID   string  `json:"span_id"`
+		ParentID string  `json:"parent_id,omitempty"`
+		Duration float64 `json:"duration"`
+	}{
+		SpanData: s,
+		TraceID:  s.TraceID.String(),
+		SpanID:   s.SpanID.String(),
+		ParentID: parentID(s.ParentID),
+		Duration: s.End.Sub(s.Start).Seconds(),
+	})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	_, err = e.w.Write(append(bs, '\n'))
+	return errors.WithStack(err)
+}
+
+func parentID(id SpanID) string {
+	if !id.IsValid() {
+		return ""
+	}
+	return id.String()
+}
@@ -0,0 +1,136 @@
+package trace
+
+import (
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// W3C trace context headers, see https://www.w3.org/TR/trace-context/
+const (
+	HeaderTraceparent = "traceparent"
+	HeaderTracestate  = "tracestate"
+
+	_version     = "00"
+	_flagSampled = 0x01
+)
+
+// TraceID is the 16 bytes trace id
+type TraceID [16]byte
+
+// String returns the lower hex of the trace id
+func (t TraceID) String() string {
+	return hex.EncodeToString(t[:])
+}
+
+// IsValid reports whether the trace id isn't all zero
+func (t TraceID) IsValid() bool {
+	return t != TraceID{}
+}
+
+// SpanID is the 8 bytes span id
+type SpanID [8]byte
+
+// String returns the lower hex of the span id
+func (s SpanID) String() string {
+	return hex.EncodeToString(s[:])
+}
+
+// IsValid reports whether the span id isn't all zero
+func (s SpanID) IsValid() bool {
+	return s != SpanID{}
+}
+
+// SpanContext is the propagated part of a span
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Flags   byte
+	// State tracestate中的厂商数据, 原样透传
+	State string
+}
+
+// IsValid reports whether both the trace id and the span id are valid
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// IsSampled reports whether the sampled flag is set
+func (sc SpanContext) IsSampled() bool {
+	return sc.Flags&_flagSampled != 0
+}
+
+// Traceparent returns the traceparent header value, e.g.
+// 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01
+func (sc SpanContext) Traceparent() string {
+	return _version + "-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-" + hex.EncodeToString([]byte{sc.Flags})
+}
+
+// ParseTraceparent parses the traceparent header value
+func ParseTraceparent(v string) (sc SpanContext, err error) {
+	parts := strings.Split(strings.TrimSpace(v), "-")
+	if len(parts) < 4 {
+		err = errors.Errorf("trace: invalid traceparent %q", v)
+		return
+	}
+	version := parts[0]
+	// 版本ff非法, 00版本必须正好4段, 更高的版本兼容解析前4段
+	if len(version) != 2 || version == "ff" || (version == _version && len(parts) != 4) {
+		err = errors.Errorf("trace: invalid traceparent version %q", v)
+		return
+	}
+	if err = decodeHex(parts[1], sc.TraceID[:]); err != nil {
+		return
+	}
+	if err = decodeHex(parts[2], sc.SpanID[:]); err != nil {
+		return
+	}
+	var flags [1]byte
+	if err = decodeHex(parts[3], flags[:]); err != nil {
+		return
+	}
+	sc.Flags = flags[0]
+	if !sc.IsValid() {
+		err = errors.Errorf("trace: invalid traceparent ids %q", v)
+	}
+	return
+}
+
+func decodeHex(s string, dst []byte) error {
+	if len(s) != hex.EncodedLen(len(dst)) || strings.ToLower(s) != s {
+		return errors.Errorf("trace: invalid hex %q", s)
+	}
+	if _, err := hex.Decode(dst, []byte(s)); err != nil {
+		return errors.Wrapf(err, "trace: invalid hex %q", s)
+	}
+	return nil
+}
+
+// Extract reads the span context from the traceparent and tracestate headers
+func Extract(header http.Header) (SpanContext, bool) {
+	v := header.Get(HeaderTraceparent)
+	if v == "" {
+		return SpanContext{}, false
+	}
+	sc, err := ParseTraceparent(v)
+	if err != nil {
+		return SpanContext{}, false
+	}
+	sc.State = strings.Join(header.Values(HeaderTracestate), ",")
+	return sc, true
+}
+
+// Inject writes the span context into the traceparent and tracestate headers
+func Inject(header http.Header, sc SpanContext) {
+	if !sc.IsValid() {
+		return
+	}
+	header.Set(HeaderTraceparent, sc.Traceparent())
+	if sc.State != "" {
+		header.Set(HeaderTracestate, sc.State)
+	} else {
+		header.Del(HeaderTracestate)
+	}
+}
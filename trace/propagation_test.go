@@ -0,0 +1,60 @@
+package trace
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestInjectExtract(t *testing.T) {
+	cases := []SpanContext{
+		{TraceID: TraceID{1}, SpanID: SpanID{2}, Flags: _flagSampled},
+		{TraceID: TraceID{0xab, 15: 0xcd}, SpanID: SpanID{7: 0xef}},
+		{TraceID: TraceID{1}, SpanID: SpanID{2}, Flags: _flagSampled, State: "congo=t61rcWkgMzE,rojo=00f067aa0ba902b7"},
+	}
+	for _, sc := range cases {
+		header := http.Header{}
+		header.Set(HeaderTracestate, "stale=1")
+		Inject(header, sc)
+		got, ok := Extract(header)
+		if !ok || got != sc {
+			t.Errorf("round trip %+v: got %+v ok %v", sc, got, ok)
+		}
+	}
+	// 非法的span context不写入请求头
+	header := http.Header{}
+	Inject(header, SpanContext{TraceID: TraceID{1}})
+	if _, ok := Extract(header); ok {
+		t.Fatal("invalid span context injected")
+	}
+}
+
+func TestExtractMalformed(t *testing.T) {
+	cases := []string{
+		"",
+		"00",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"0-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e47zz-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-1",
+	}
+	for _, v := range cases {
+		header := http.Header{}
+		header.Set(HeaderTraceparent, v)
+		if sc, ok := Extract(header); ok {
+			t.Errorf("malformed %q extracted: %+v", v, sc)
+		}
+	}
+	// 更高的版本兼容解析前4段
+	header := http.Header{}
+	header.Set(HeaderTraceparent, "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-future")
+	sc, ok := Extract(header)
+	if !ok || sc.Traceparent() != "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01" {
+		t.Fatalf("future version: %+v %v", sc, ok)
+	}
+}
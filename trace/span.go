@@ -0,0 +1,156 @@
+// Package trace is a small distributed tracing library with W3C trace context propagation
+package trace
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// span kinds
+const (
+	KindServer = "server"
+	KindClient = "client"
+)
+
+// common tags
+const (
+	TagRoute      = "http.route"
+	TagMethod     = "http.method"
+	TagURL        = "http.url"
+	TagStatusCode = "http.status_code"
+	TagErrorCode  = "error.code"
+	TagError      = "error"
+)
+
+// Tracer creates spans and exports the sampled ones when they finish
+type Tracer struct {
+	exporter Exporter
+	// sample 新建trace的采样率, 继承的trace使用上游的采样标记
+	sample float64
+
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+// NewTracer new a tracer with the exporter, sample is the rate of sampling new traces in [0, 1]
+func NewTracer(exporter Exporter, sample float64) *Tracer {
+	return &Tracer{
+		exporter: exporter,
+		sample:   sample,
+		rand:     rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// StartSpan starts a span, it's a child of parent if parent is valid, otherwise a new trace
+func (t *Tracer) StartSpan(name, kind string, parent SpanContext) *Span {
+	s := &Span{
+		tracer: t,
+		data: SpanData{
+			Name:  name,
+			Kind:  kind,
+			Start: time.Now(),
+			Tags:  make(map[string]interface{}),
+		},
+	}
+	t.mu.Lock()
+	if parent.IsValid() {
+		s.data.TraceID = parent.TraceID
+		s.data.ParentID = parent.SpanID
+		s.data.Flags = parent.Flags
+		s.data.State = parent.State
+	} else {
+		t.rand.Read(s.data.TraceID[:])
+		if t.sample >= 1 || t.rand.Float64() < t.sample {
+			s.data.Flags |= _flagSampled
+		}
+	}
+	t.rand.Read(s.data.SpanID[:])
+	t.mu.Unlock()
+	// 全0的id非法
+	if !s.data.TraceID.IsValid() {
+		s.data.TraceID[15] = 1
+	}
+	if !s.data.SpanID.IsValid() {
+		s.data.SpanID[7] = 1
+	}
+	return s
+}
+
+// SpanData is the finished span exported by Exporter
+type SpanData struct {
+	Name     string                 `json:"name"`
+	Kind     string                 `json:"kind"`
+	TraceID  TraceID                `json:"-"`
+	SpanID   SpanID                 `json:"-"`
+	ParentID SpanID                 `json:"-"`
+	Flags    byte                   `json:"-"`
+	State    string                 `json:"-"`
+	Start    time.Time              `json:"start"`
+	End      time.Time              `json:"end"`
+	Tags     map[string]interface{} `json:"tags,omitempty"`
+}
+
+// Span is a timed operation of a trace
+type Span struct {
+	tracer   *Tracer
+	mu       sync.Mutex
+	data     SpanData
+	finished bool
+}
+
+// Context returns the span context used to propagate
+func (s *Span) Context() SpanContext {
+	return SpanContext{
+		TraceID: s.data.TraceID,
+		SpanID:  s.data.SpanID,
+		Flags:   s.data.Flags,
+		State:   s.data.State,
+	}
+}
+
+// Tracer returns the tracer created the span
+func (s *Span) Tracer() *Tracer {
+	return s.tracer
+}
+
+// SetTag sets a tag on the span
+func (s *Span) SetTag(key string, value interface{}) {
+	s.mu.Lock()
+	s.data.Tags[key] = value
+	s.mu.Unlock()
+}
+
+// Finish ends the span and exports it if sampled, only the first call takes effect
+func (s *Span) Finish() {
+	s.mu.Lock()
+	if s.finished {
+		s.mu.Unlock()
+		return
+	}
+	s.finished = true
+	s.data.End = time.Now()
+	data := s.data
+	data.Tags = make(map[string]interface{}, len(s.data.Tags))
+	for k, v := range s.data.Tags {
+		data.Tags[k] = v
+	}
+	s.mu.Unlock()
+	if s.tracer.exporter != nil && data.Flags&_flagSampled != 0 {
+		s.tracer.exporter.Export(&data)
+	}
+}
+
+type spanKey struct{}
+
+// NewContext returns a new context with the span attached
+func NewContext(ctx context.Context, s *Span) context.Context {
+	return context.WithValue(ctx, spanKey{}, s)
+}
+
+// FromContext returns the span in ctx if it exists
+func FromContext(ctx context.Context) (s *Span, ok bool) {
+	s, ok = ctx.Value(spanKey{}).(*Span)
+	return
+}
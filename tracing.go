@@ -0,0 +1,47 @@
+package pudding
+
+import (
+	"strconv"
+
+	"github.com/bdjimmy/pudding/metadata"
+	"github.com/bdjimmy/pudding/trace"
+)
+
+// Trace returns the tracing middleware which reads the W3C traceparent/tracestate headers,
+// starts a server span per request and puts the trace and span ids into metadata.MD
+// 每个请求一个server span, trace_id和span_id放到metadata中, 供访问日志和客户端使用
+func Trace(tracer *trace.Tracer) HandlerFunc {
+	return func(c *Context) {
+		req := c.Request
+		parent, _ := trace.Extract(req.Header)
+		route := c.RoutePath
+		if route == "" {
+			route = _metricUnmatchedPath
+		}
+		span := tracer.StartSpan(req.Method+" "+route, trace.KindServer, parent)
+		span.SetTag(trace.TagMethod, req.Method)
+		span.SetTag(trace.TagRoute, route)
+		span.SetTag(trace.TagURL, req.URL.Path)
+		defer span.Finish()
+
+		// FromContext返回的MD不能修改, 复制一份再写入
+		md, _ := metadata.FromContext(c)
+		md = md.Copy()
+		sc := span.Context()
+		md[metadata.TraceID] = sc.TraceID.String()
+		md[metadata.SpanID] = sc.SpanID.String()
+		c.Context = trace.NewContext(metadata.NewContext(c.Context, md), span)
+
+		c.Next()
+
+		if rw, ok := c.Writer.(ResponseWriter); ok {
+			span.SetTag(trace.TagStatusCode, rw.Status())
+		}
+		if code, err := strconv.Atoi(c.Writer.Header().Get(_httpHeaderStatusCode)); err == nil {
+			span.SetTag(trace.TagErrorCode, code)
+		}
+		if c.Error != nil {
+			span.SetTag(trace.TagError, c.Error.Error())
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package pudding
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// routeHandlers 返回只用于标识路由的处理链
+func routeHandlers(route string) []HandlerFunc {
+	return []HandlerFunc{func(c *Context) { c.Keys = map[string]interface{}{"route": route} }}
+}
+
+func TestTreeGetValue(t *testing.T) {
+	tree := &node{}
+	routes := []string{
+		"/",
+		"/cmd/:tool/:sub",
+		"/cmd/:tool/",
+		"/src/*filepath",
+		"/search/",
+		"/search/:query",
+		"/user_:name",
+		"/user_:name/about",
+		"/files/:dir/*filepath",
+		"/doc/",
+		"/doc/go_faq.html",
+		"/info/:user/public",
+		"/info/:user/project/:project",
+	}
+	for _, route := range routes {
+		tree.addRoute(route, routeHandlers(route))
+	}
+	cases := []struct {
+		path   string
+		route  string
+		params Params
+	}{
+		{"/", "/", nil},
+		{"/cmd/test/", "/cmd/:tool/", Params{{"tool", "test"}}},
+		{"/cmd/test", "", nil},
+		{"/cmd/test/3", "/cmd/:tool/:sub", Params{{"tool", "test"}, {"sub", "3"}}},
+		{"/src/", "/src/*filepath", Params{{"filepath", "/"}}},
+		{"/src/some/file.png", "/src/*filepath", Params{{"filepath", "/some/file.png"}}},
+		{"/search/", "/search/", nil},
+		{"/search/someth!ng+in+ünìcodé", "/search/:query", Params{{"query", "someth!ng+in+ünìcodé"}}},
+		{"/search/someth!ng+in+ünìcodé/", "", nil},
+		{"/user_gopher", "/user_:name", Params{{"name", "gopher"}}},
+		{"/user_gopher/about", "/user_:name/about", Params{{"name", "gopher"}}},
+		{"/files/js/inc/framework.js", "/files/:dir/*filepath", Params{{"dir", "js"}, {"filepath", "/inc/framework.js"}}},
+		{"/info/gordon/public", "/info/:user/public", Params{{"user", "gordon"}}},
+		{"/info/gordon/project/go", "/info/:user/project/:project", Params{{"user", "gordon"}, {"project", "go"}}},
+		{"/doc", "", nil},
+		{"/doc/go_faq.html/", "", nil},
+		{"/missing", "", nil},
+	}
+	for _, cs := range cases {
+		value := tree.getValue(cs.path, nil)
+		if cs.route == "" {
+			if value.handlers != nil {
+				t.Errorf("%s: matched %s", cs.path, value.fullPath)
+			}
+			continue
+		}
+		if value.handlers == nil {
+			t.Errorf("%s: not matched", cs.path)
+			continue
+		}
+		c := &Context{}
+		value.handlers[0](c)
+		if c.Keys["route"] != cs.route || value.fullPath != cs.route {
+			t.Errorf("%s: matched %v fullPath %s, want %s", cs.path, c.Keys["route"], value.fullPath, cs.route)
+		}
+		if len(cs.params) > 0 || len(value.params) > 0 {
+			if !reflect.DeepEqual(value.params, cs.params) {
+				t.Errorf("%s: params %v, want %v", cs.path, value.params, cs.params)
+			}
+		}
+	}
+}
+
+func TestTreeParamsReuse(t *testing.T) {
+	tree := &node{}
+	tree.addRoute("/user/:id", routeHandlers("/user/:id"))
+	po := make(Params, 0, 4)
+	value := tree.getValue("/user/42", po)
+	if len(value.params) != 1 || &value.params[:1][0] != &po[:1][0] {
+		t.Fatalf("params not appended to the given slice: %v", value.params)
+	}
+	if id := value.params.ByName("id"); id != "42" {
+		t.Fatalf("id: %s", id)
+	}
+	if _, ok := value.params.Get("name"); ok {
+		t.Fatal("unknown param found")
+	}
+}
+
+func TestTreePriority(t *testing.T) {
+	tree := &node{}
+	for _, route := range []string{"/a", "/b", "/b/1", "/b/2", "/c", "/c/1", "/c/2", "/c/3"} {
+		tree.addRoute(route, routeHandlers(route))
+	}
+	// 子节点按注册到该子树的路由数排序, 路由多的子树优先匹配
+	if tree.indices != "cba" {
+		t.Fatalf("indices: %q", tree.indices)
+	}
+	var check func(n *node)
+	check = func(n *node) {
+		for i, child := range n.children {
+			if i > 0 && n.children[i-1].priority < child.priority {
+				t.Errorf("%s: children not ordered by priority", n.path)
+			}
+			check(child)
+		}
+	}
+	check(tree)
+	for _, route := range []string{"/a", "/b/2", "/c/3"} {
+		if value := tree.getValue(route, nil); value.fullPath != route {
+			t.Errorf("%s: matched %s", route, value.fullPath)
+		}
+	}
+}
+
+func TestTreeConflicts(t *testing.T) {
+	cases := []struct {
+		routes []string
+		panic  string
+	}{
+		{[]string{"/user/:id", "/user/:name"}, "conflicts with existing wildcard"},
+		{[]string{"/user/:id", "/user/new"}, "conflicts with existing wildcard"},
+		{[]string{"/user/new", "/user/:id"}, "conflicts with existing children"},
+		{[]string{"/src/*filepath", "/src/*other"}, "conflicts with existing wildcard"},
+		{[]string{"/src/", "/src/*filepath"}, "catch-all conflicts with existing handle"},
+		{[]string{"/user/:id", "/user/:id"}, "handlers are already registered"},
+		{[]string{"/user/:id:name"}, "only one wildcard per path segment"},
+		{[]string{"/user/:"}, "wildcards must be named"},
+		{[]string{"/src/*filepath/x"}, "catch-all routes are only allowed at the end"},
+		{[]string{"/src*filepath"}, "no / before catch-all"},
+	}
+	for _, cs := range cases {
+		func() {
+			defer func() {
+				r := recover()
+				msg, _ := r.(string)
+				if !strings.Contains(msg, cs.panic) {
+					t.Errorf("%v: panic %v, want %q", cs.routes, r, cs.panic)
+				}
+			}()
+			tree := &node{}
+			for _, route := range cs.routes {
+				tree.addRoute(route, routeHandlers(route))
+			}
+		}()
+	}
+}
+
+func TestMethodTrees(t *testing.T) {
+	engine := New()
+	engine.GET("/user/:id", func(c *Context) { c.String(http.StatusOK, "get "+c.Param("id")) })
+	engine.POST("/user/:name", func(c *Context) { c.String(http.StatusOK, "post "+c.Param("name")) })
+	if engine.trees.get(http.MethodGet) == engine.trees.get(http.MethodPost) {
+		t.Fatal("methods share a tree")
+	}
+	if engine.trees.get(http.MethodPut) != nil {
+		t.Fatal("tree of an unregistered method")
+	}
+	for method, want := range map[string]string{http.MethodGet: "get 1", http.MethodPost: "post 1"} {
+		req, _ := http.NewRequest(method, "/user/1", nil)
+		req.RemoteAddr = "127.0.0.1:1234"
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		if w.Body.String() != want {
+			t.Errorf("%s: %s", method, w.Body.String())
+		}
+	}
+}
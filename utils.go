@@ -1,8 +1,11 @@
 package pudding
 
 import (
+	"net/http"
 	"os"
 	"path"
+	"reflect"
+	"runtime"
 )
 
 // lastChar 获取字符串最后一个字符
@@ -41,3 +44,22 @@ func resolveAddress(addr []string) string {
 		panic("too much parameters")
 	}
 }
+
+// WrapF is a helper function for wrapping http.HandlerFunc and returns a pudding middleware.
+func WrapF(f http.HandlerFunc) HandlerFunc {
+	return func(c *Context) {
+		f(c.Writer, c.Request)
+	}
+}
+
+// WrapH is a helper function for wrapping http.Handler and returns a pudding middleware.
+func WrapH(h http.Handler) HandlerFunc {
+	return func(c *Context) {
+		h.ServeHTTP(c.Writer, c.Request)
+	}
+}
+
+// nameOfFunction 返回函数的完整名字, 例如 main.handler
+func nameOfFunction(f interface{}) string {
+	return runtime.FuncForPC(reflect.ValueOf(f).Pointer()).Name()
+}
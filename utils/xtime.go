@@ -10,4 +10,9 @@ func (d *Duration) UnmarshalText(text []byte) error{
 		*d = Duration(t)
 	}
 	return err
-}
\ No newline at end of file
+}
+
+// MarshalText encodes the duration as a string, e.g. 1.5s
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}